@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/spotify-genre-organizer/backend/internal/api"
+	"github.com/spotify-genre-organizer/backend/internal/api/handlers"
+	"github.com/spotify-genre-organizer/backend/internal/artistcache"
+	"github.com/spotify-genre-organizer/backend/internal/auth"
+	"github.com/spotify-genre-organizer/backend/internal/autosync"
 	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/library"
+	"github.com/spotify-genre-organizer/backend/internal/organizer"
 )
 
 func main() {
@@ -23,8 +33,48 @@ func main() {
 		log.Printf("Spotify Client ID loaded: %s...", clientID[:8])
 	}
 
+	var jobStore jobs.Store = jobs.NewDatabaseStore()
+	var planStore jobs.PlanStore = jobs.NewDatabasePlanStore()
+	var libraryStore library.Store = library.NewDatabaseStore()
+	var artistStore artistcache.Store = artistcache.NewDatabaseStore()
+	autoSync := true
 	if err := database.Init(); err != nil {
 		log.Printf("Warning: Could not connect to Supabase: %v", err)
+		log.Println("Organize jobs, plans, organized playlists, library snapshots and artist genres will be kept in memory and lost on restart, and auto-sync is off")
+		jobStore = jobs.NewMemoryStore()
+		planStore = jobs.NewMemoryPlanStore()
+		libraryStore = library.NewMemoryStore()
+		organizer.SetOverrideStore(organizer.NewMemoryOverrideStore())
+		artistStore = nil
+		autoSync = false
+	}
+
+	// Tokens are only stored encrypted. Without a key they are kept in memory,
+	// so sessions end on restart and auto-sync can't act for users.
+	var tokenStore auth.TokenStore
+	var sessionStore auth.SessionStore = auth.NewMemorySessionStore()
+	if cipher, err := auth.CipherFromEnv(); err != nil {
+		log.Printf("Warning: %v, Spotify tokens and sessions will be kept in memory and auto-sync is off", err)
+		autoSync = false
+	} else if autoSync {
+		tokenStore = auth.NewDatabaseTokenStore(cipher)
+		sessionStore = auth.NewDatabaseSessionStore(cipher)
+	}
+	sessionKey, err := auth.SessionKeyFromEnv()
+	if err != nil {
+		log.Printf("Warning: %v, sessions will end when the server restarts", err)
+	}
+
+	handlers.SetTokenStore(tokenStore)
+	handlers.SetSessions(sessionStore, sessionKey)
+	handlers.SetJobStore(jobStore)
+	handlers.SetPlanStore(planStore)
+	handlers.SetLibraryStore(libraryStore)
+	handlers.SetArtistGenreCache(artistcache.New(artistStore, 0, artistGenreTTL()))
+	go jobs.RunRetention(context.Background(), jobStore, planStore, jobRetention())
+	go auth.RunSessionPruning(context.Background(), sessionStore)
+	if autoSync {
+		go autosync.New(autosync.NewDatabaseStore(), handlers.AutoSync, 0).Run(context.Background())
 	}
 
 	port := os.Getenv("PORT")
@@ -40,3 +90,23 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// jobRetention reads how many days of organize job history to keep from
+// JOB_RETENTION_DAYS
+func jobRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("JOB_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return jobs.DefaultRetention
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// artistGenreTTL reads how many hours cached artist genres stay fresh from
+// ARTIST_GENRE_TTL_HOURS
+func artistGenreTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("ARTIST_GENRE_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		return artistcache.DefaultTTL
+	}
+	return time.Duration(hours) * time.Hour
+}
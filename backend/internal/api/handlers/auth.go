@@ -3,11 +3,15 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/auth"
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
 	"github.com/spotify-genre-organizer/backend/internal/spotify"
 )
 
@@ -68,35 +72,50 @@ func Callback(c *gin.Context) {
 		return
 	}
 
-	tokens, err := getSpotifyConfig().ExchangeCode(code)
+	ctx := c.Request.Context()
+	tokens, err := getSpotifyConfig().ExchangeCode(ctx, code)
 	if err != nil {
 		c.Redirect(http.StatusTemporaryRedirect, os.Getenv("FRONTEND_URL")+"?error=token_exchange_failed")
 		return
 	}
 
-	profile, err := spotify.GetUserProfile(tokens.AccessToken)
+	profile, err := getSpotifyConfig().NewClient(tokens.AccessToken).GetUserProfile(ctx)
 	if err != nil {
 		c.Redirect(http.StatusTemporaryRedirect, os.Getenv("FRONTEND_URL")+"?error=profile_fetch_failed")
 		return
 	}
 
-	expiresAt := time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
-	secure := isProduction()
-	setCookie(c, "user_id", profile.ID, tokens.ExpiresIn, "/", secure, true)
-	setCookie(c, "access_token", tokens.AccessToken, tokens.ExpiresIn, "/", secure, true)
-	_ = expiresAt
+	user := &models.User{
+		SpotifyID:   profile.ID,
+		DisplayName: profile.DisplayName,
+		Email:       profile.Email,
+	}
+	if err := database.UpsertUser(user); err != nil {
+		log.Printf("Failed to save user %s: %v", profile.ID, err)
+	}
 
-	c.Redirect(http.StatusTemporaryRedirect, os.Getenv("FRONTEND_URL")+"/dashboard")
-}
+	// The session acts for the user in the browser; the user's own copy of
+	// the tokens lets auto-sync act for them while they're away
+	token := auth.NewToken(tokens, time.Now())
+	if err := getTokens().Save(profile.ID, token); err != nil {
+		log.Printf("Failed to store tokens for %s: %v", profile.ID, err)
+	}
 
-func Me(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
+	_, cookie, err := getSessions().Create(profile.ID, token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		log.Printf("Failed to create session for %s: %v", profile.ID, err)
+		c.Redirect(http.StatusTemporaryRedirect, os.Getenv("FRONTEND_URL")+"?error=session_failed")
 		return
 	}
 
-	profile, err := spotify.GetUserProfile(accessToken)
+	clearLegacyCookies(c)
+	setCookie(c, sessionCookie, cookie, int(auth.SessionLifetime/time.Second), "/", isProduction(), true)
+
+	c.Redirect(http.StatusTemporaryRedirect, os.Getenv("FRONTEND_URL")+"/dashboard")
+}
+
+func Me(c *gin.Context) {
+	profile, err := sessionClient(c).GetUserProfile(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
@@ -110,9 +129,15 @@ func Me(c *gin.Context) {
 }
 
 func Logout(c *gin.Context) {
+	if cookie, err := c.Cookie(sessionCookie); err == nil {
+		if err := getSessions().Delete(cookie); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+		}
+	}
+
 	secure := isProduction()
-	setCookie(c, "user_id", "", -1, "/", secure, true)
-	setCookie(c, "access_token", "", -1, "/", secure, true)
+	clearLegacyCookies(c)
+	setCookie(c, sessionCookie, "", -1, "/", secure, true)
 	setCookie(c, "oauth_state", "", -1, "/", secure, true)
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/auth"
+)
+
+// cookieValue returns the value w sets for the named cookie
+func cookieValue(w *httptest.ResponseRecorder, name string) (string, bool) {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name && cookie.MaxAge >= 0 {
+			return cookie.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestSignInStartsServerSideSession(t *testing.T) {
+	_, srv, _ := newOrganizeTestServerWithSpotify(t, 0)
+	r := gin.New()
+	r.GET("/api/auth/callback", Callback)
+	r.GET("/api/auth/me", RequireSession, Me)
+	r.POST("/api/auth/logout", Logout)
+
+	srv.AddAuthCode("code", "bob")
+	req := httptest.NewRequest("GET", "/api/auth/callback?code=code&state=state", nil)
+	req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "state"})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	session, ok := cookieValue(w, sessionCookie)
+	if w.Code != http.StatusTemporaryRedirect || !ok {
+		t.Fatalf("expected a redirect signing bob in, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, name := range []string{"access_token", "user_id"} {
+		if _, ok := cookieValue(w, name); ok {
+			t.Errorf("expected no %s cookie", name)
+		}
+	}
+	if strings.Contains(session, "bob") || strings.Contains(session, "token") {
+		t.Errorf("expected an opaque session cookie, got %q", session)
+	}
+
+	me := func(session string) (int, string) {
+		w := doRequest(r, "GET", "/api/auth/me", "", session)
+		var profile struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &profile)
+		return w.Code, profile.ID
+	}
+
+	if code, id := me(session); code != http.StatusOK || id != "bob" {
+		t.Fatalf("expected bob's profile, got %d %q", code, id)
+	}
+
+	// Spotify stops accepting the token, as when it expires
+	stored, _ := getSessions().Get(session)
+	srv.RevokeToken(stored.AccessToken)
+	if code, id := me(session); code != http.StatusOK || id != "bob" {
+		t.Errorf("expected the session's token to be refreshed, got %d %q", code, id)
+	}
+
+	// Claiming to be someone else takes more than a cookie naming them
+	req = httptest.NewRequest("GET", "/api/auth/me", nil)
+	req.AddCookie(&http.Cookie{Name: "user_id", Value: "bob"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a user_id cookie alone to be rejected, got %d", w.Code)
+	}
+	if code, _ := me(session + "x"); code != http.StatusUnauthorized {
+		t.Errorf("expected a tampered session cookie to be rejected, got %d", code)
+	}
+
+	w = doRequest(r, "POST", "/api/auth/logout", "", session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: %d", w.Code)
+	}
+	if code, _ := me(session); code != http.StatusUnauthorized {
+		t.Errorf("expected the session to be over after logging out, got %d", code)
+	}
+}
+
+// Run with -race: the first requests after startup arrive together
+func TestConcurrentRequestsShareSessions(t *testing.T) {
+	r, _ := newOrganizeTestServer(t, 0)
+	r.GET("/api/whoami", func(c *gin.Context) { c.String(http.StatusOK, sessionUserID(c)) })
+
+	// Sign in through another replica sharing the store and secret, so the
+	// requests below are the first to use this one's sessions
+	store := auth.NewMemorySessionStore()
+	key := []byte(strings.Repeat("k", 32))
+	SetSessions(store, key)
+	_, session, err := auth.NewSessions(getSpotifyConfig(), store, key).Create("alice", auth.Token{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := doRequest(r, "GET", "/api/whoami", "", session); w.Code != http.StatusOK || w.Body.String() != "alice" {
+				t.Errorf("expected every request to find alice's session, got %d %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/autosync"
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// autoSyncRunsLimit is how many recent runs GetAutoSyncRuns returns
+const autoSyncRunsLimit = 20
+
+// AutoSync is the autosync.SyncFunc. It syncs the user's playlists as
+// sync-all does, with the tokens stored when they last signed in, refreshed
+// as needed. Like other jobs it waits for a free worker, and it is skipped if
+// the user already has a job queued or running.
+func AutoSync(ctx context.Context, run *models.AutoSyncRun) error {
+	reservation, active := jobScheduler.Reserve(run.UserID, jobs.ActiveJob{
+		ID:   run.ID,
+		Kind: jobs.KindSync,
+	})
+	if reservation == nil {
+		return fmt.Errorf("%s job %s is running: %w", active.Kind, active.ID, autosync.ErrSkipped)
+	}
+
+	var err error
+	<-reservation.Start(ctx, func(ctx context.Context) {
+		err = autoSync(ctx, run)
+	})
+	return err
+}
+
+func autoSync(ctx context.Context, run *models.AutoSyncRun) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	source, err := getTokens().Source(run.UserID)
+	if err != nil {
+		return err
+	}
+
+	response, err := syncAll(ctx, getSpotifyConfig().NewClientWithTokens(source), run.UserID)
+	if err != nil {
+		return err
+	}
+
+	run.PlaylistsUpdated = response.PlaylistsUpdated
+	run.TracksAdded = response.TracksAdded
+	run.TracksRemoved = response.TracksRemoved
+	if len(response.FailedPlaylists) > 0 {
+		return fmt.Errorf("failed to sync %s", strings.Join(response.FailedPlaylists, ", "))
+	}
+	return nil
+}
+
+// GetAutoSyncRuns returns the user's auto-sync schedule and their most
+// recent runs, newest first
+func GetAutoSyncRuns(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settings"})
+		return
+	}
+
+	runs, err := database.ListAutoSyncRuns(userID, autoSyncRunsLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch auto-sync runs"})
+		return
+	}
+	if runs == nil {
+		runs = []models.AutoSyncRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule": settings.AutoSync,
+		"runs":     runs,
+	})
+}
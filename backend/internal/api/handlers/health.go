@@ -4,13 +4,30 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/artistcache"
 )
 
+// artistCache is reported on by the health check when set
+var artistCache *artistcache.Cache
+
+// SetArtistGenreCache makes Spotify clients look artist genres up in cache
+// before asking Spotify, and reports its hit and miss counters in the health
+// check
+func SetArtistGenreCache(cache *artistcache.Cache) {
+	artistCache = cache
+	getSpotifyConfig().ArtistGenres = cache
+}
+
 func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":  "ok",
 		"service": "spotify-genre-organizer",
-	})
+	}
+	if artistCache != nil {
+		response["artist_genre_cache"] = artistCache.Stats()
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func NotImplemented(c *gin.Context) {
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spotify-genre-organizer/backend/internal/spotify"
+	"github.com/spotify-genre-organizer/backend/internal/library"
 )
 
 type LibraryCountResponse struct {
@@ -16,14 +17,18 @@ type LibraryCountResponse struct {
 // Simple in-memory cache (per-user)
 var countCache = make(map[string]*LibraryCountResponse)
 
-func GetLibraryCount(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+var libraryStore library.Store = library.NewDatabaseStore()
 
-	userID, _ := c.Cookie("user_id")
+// SetLibraryStore replaces where library snapshots are kept, e.g. with an
+// in-memory store when no database is configured
+func SetLibraryStore(store library.Store) {
+	libraryStore = store
+}
+
+func GetLibraryCount(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
 	// Check cache (5 minute TTL)
 	if cached, ok := countCache[userID]; ok {
@@ -34,7 +39,7 @@ func GetLibraryCount(c *gin.Context) {
 	}
 
 	// Fetch count from Spotify
-	count, err := spotify.GetLikedSongsCount(accessToken)
+	count, err := client.GetLikedSongsCount(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch count"})
 		return
@@ -48,3 +53,44 @@ func GetLibraryCount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+type GenreCount struct {
+	Genre     string `json:"genre"`
+	SongCount int    `json:"song_count"`
+}
+
+type LibraryGenresResponse struct {
+	TotalSongs int          `json:"total_songs"`
+	Genres     []GenreCount `json:"genres"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// GetLibraryGenres breaks the user's liked songs down by parent genre, using
+// the classification stored with their library snapshot
+func GetLibraryGenres(c *gin.Context) {
+	userID := sessionUserID(c)
+	client := sessionClient(c)
+
+	snapshot, _, err := library.Refresh(c.Request.Context(), client, libraryStore, userID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch songs"})
+		return
+	}
+
+	genres := []GenreCount{}
+	for genre, songs := range snapshot.ByParentGenre() {
+		genres = append(genres, GenreCount{Genre: genre, SongCount: len(songs)})
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if genres[i].SongCount != genres[j].SongCount {
+			return genres[i].SongCount > genres[j].SongCount
+		}
+		return genres[i].Genre < genres[j].Genre
+	})
+
+	c.JSON(http.StatusOK, LibraryGenresResponse{
+		TotalSongs: len(snapshot.Songs),
+		Genres:     genres,
+		UpdatedAt:  snapshot.UpdatedAt,
+	})
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/library"
+	"github.com/spotify-genre-organizer/backend/internal/models"
 	"github.com/spotify-genre-organizer/backend/internal/organizer"
 	"github.com/spotify-genre-organizer/backend/internal/spotify"
 )
@@ -14,36 +20,81 @@ import (
 type OrganizeRequest struct {
 	PlaylistCount   int  `json:"playlist_count" binding:"required,min=1,max=50"`
 	ReplaceExisting bool `json:"replace_existing"`
+
+	// DryRun only works out which playlists would be written and saves them
+	// as an editable plan, without changing anything on Spotify
+	DryRun bool `json:"dry_run"`
 }
 
 type JobStatus struct {
 	ID               string                    `json:"id"`
+	PlaylistCount    int                       `json:"playlist_count"`
+	ReplaceExisting  bool                      `json:"replace_existing"`
+	DryRun           bool                      `json:"dry_run"`
 	Status           string                    `json:"status"`
 	Stage            string                    `json:"stage"`
 	SongsProcessed   int                       `json:"songs_processed"`
 	TotalSongs       int                       `json:"total_songs"`
 	GenresDiscovered []string                  `json:"genres_discovered"`
 	Result           *organizer.OrganizeResult `json:"result,omitempty"`
+	PlanID           string                    `json:"plan_id,omitempty"`
+	Preview          *models.OrganizePlan      `json:"preview,omitempty"`
 	Error            string                    `json:"error,omitempty"`
+	CreatedAt        time.Time                 `json:"created_at"`
+	CompletedAt      *time.Time                `json:"completed_at,omitempty"`
+}
+
+// runningJob is an organize job executing in this process
+type runningJob struct {
+	*jobs.Job
+	cancel context.CancelFunc
+	done   chan struct{} // closed once the job has finished
 }
 
 var (
-	jobs   = make(map[string]*JobStatus)
-	jobsMu sync.RWMutex
+	runningJobs = make(map[string]*runningJob)
+	jobsMu      sync.RWMutex
+
+	jobStore jobs.Store = jobs.NewDatabaseStore()
+
+	// jobEvents streams progress of jobs running in this process to
+	// GetOrganizeEvents
+	jobEvents = jobs.NewBroker()
 )
 
-func StartOrganize(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+// SetJobStore replaces where organize jobs are persisted, e.g. with an
+// in-memory store when no database is configured
+func SetJobStore(store jobs.Store) {
+	jobStore = store
+}
 
-	userID, err := c.Cookie("user_id")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+const (
+	// organizeJobTimeout bounds how long a background organize job may run
+	organizeJobTimeout = 30 * time.Minute
+
+	// cancelWaitTimeout is how long CancelOrganize waits for the job to stop
+	// before responding with its in-progress state
+	cancelWaitTimeout = 10 * time.Second
+
+	// progressSaveInterval throttles how often progress counters are written
+	// to the job store; stage changes are always written
+	progressSaveInterval = 2 * time.Second
+
+	// staleJobTimeout is how long an unfinished job may go without an update
+	// before we assume the process running it died
+	staleJobTimeout = 10 * time.Minute
+
+	// eventsHeartbeatInterval keeps idle event streams from being closed by
+	// proxies while a long stage runs
+	eventsHeartbeatInterval = 15 * time.Second
+
+	// remoteJobPollInterval is how often the event stream re-reads a job
+	// running on another instance, since its events aren't visible here
+	remoteJobPollInterval = 2 * time.Second
+)
+
+func StartOrganize(c *gin.Context) {
+	userID := sessionUserID(c)
 
 	var req OrganizeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -51,67 +102,223 @@ func StartOrganize(c *gin.Context) {
 		return
 	}
 
-	// Create job
+	// Only one job may modify a user's playlists at a time. Asking again
+	// while an organize job is queued or running returns that job. Dry runs
+	// change nothing, so they don't take the user's slot.
 	jobID := uuid.New().String()
-	job := &JobStatus{
-		ID:     jobID,
-		Status: "pending",
-		Stage:  "initializing",
+	var reservation *jobs.Reservation
+	if !req.DryRun {
+		var active jobs.ActiveJob
+		reservation, active = jobScheduler.Reserve(userID, jobs.ActiveJob{ID: jobID, Kind: jobs.KindOrganize})
+		if reservation == nil {
+			respondExistingJob(c, active)
+			return
+		}
 	}
 
-	jobsMu.Lock()
-	jobs[jobID] = job
-	jobsMu.Unlock()
+	// Create job
+	now := time.Now()
+	record := &models.OrganizeJob{
+		ID:              jobID,
+		UserID:          userID,
+		PlaylistCount:   req.PlaylistCount,
+		ReplaceExisting: req.ReplaceExisting,
+		DryRun:          req.DryRun,
+		Status:          models.JobStatusPending,
+		Stage:           "queued",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := jobStore.Create(record); err != nil {
+		if reservation != nil {
+			reservation.Release()
+		}
+		log.Printf("failed to create organize job for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start organize job"})
+		return
+	}
 
-	// Start async processing
-	go processOrganizeJob(job, accessToken, userID, req)
+	launchJob(record, reservation, sessionClient(c), func(ctx context.Context, run *jobRun, client *spotify.Client) {
+		processOrganizeJob(ctx, run, client, userID, req)
+	})
 
 	c.JSON(http.StatusAccepted, gin.H{
-		"job_id": jobID,
-		"status": "pending",
+		"job_id": record.ID,
+		"status": models.JobStatusPending,
 	})
 }
 
-func processOrganizeJob(job *JobStatus, accessToken, userID string, req OrganizeRequest) {
-	updateJob := func() {
+// respondExistingJob answers a start request made while the user already has
+// a job modifying their playlists: an organize job is handed back so the
+// client can follow it, anything else is a conflict
+func respondExistingJob(c *gin.Context, active jobs.ActiveJob) {
+	if active.Kind != jobs.KindOrganize {
+		respondJobBusy(c, active)
+		return
+	}
+
+	status := models.JobStatusPending
+	if record, err := findJob(active.ID); err == nil && record != nil {
+		status = record.Status
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":   active.ID,
+		"status":   status,
+		"existing": true,
+	})
+}
+
+// launchJob registers record as running in this process and queues work for
+// a worker; the job stays "queued" until one is free. A nil reservation runs
+// the job without claiming the user's slot. The job keeps using client, whose
+// token is refreshed if the job outlasts it.
+func launchJob(record *models.OrganizeJob, reservation *jobs.Reservation, client *spotify.Client, work func(ctx context.Context, run *jobRun, client *spotify.Client)) {
+	// The job outlives the request that started it, so it gets its own context
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &runningJob{
+		Job:    jobs.NewJob(record),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	jobsMu.Lock()
+	runningJobs[record.ID] = job
+	jobsMu.Unlock()
+
+	exec := func(ctx context.Context) {
+		executeJob(ctx, job, client, work)
+	}
+	if reservation != nil {
+		reservation.Start(ctx, exec)
+	} else {
+		jobScheduler.Go(ctx, exec)
+	}
+}
+
+// executeJob runs work for a job picked up by a worker and cleans up after it
+func executeJob(ctx context.Context, job *runningJob, client *spotify.Client, work func(ctx context.Context, run *jobRun, client *spotify.Client)) {
+	jobID := job.Snapshot().ID
+	defer func() {
+		// Finished jobs are served from the store from now on
 		jobsMu.Lock()
-		jobs[job.ID] = job
+		delete(runningJobs, jobID)
 		jobsMu.Unlock()
+		jobEvents.Close(jobID)
+		close(job.done)
+	}()
+	defer job.cancel()
+
+	// The timeout starts once a worker picks the job up, not while queued
+	ctx, cancelTimeout := context.WithTimeout(ctx, organizeJobTimeout)
+	defer cancelTimeout()
+
+	defer func() {
+		stats := client.Stats()
+		log.Printf("organize job %s: %d spotify requests, %d retries (%d rate limited)",
+			jobID, stats.Requests, stats.Retries, stats.RateLimited)
+	}()
+
+	run := &jobRun{job: job, id: jobID, lastSaved: time.Now()}
+
+	// Cancelled while still queued
+	if err := ctx.Err(); err != nil {
+		run.fail(err, "")
+		return
 	}
 
-	job.Status = "processing"
-	job.Stage = "fetching"
-	updateJob()
+	work(ctx, run, client)
+}
+
+// jobRun records a running job's progress, persisting it and publishing it
+// to event subscribers. It is only used from the job's own goroutine.
+type jobRun struct {
+	job       *runningJob
+	id        string
+	lastSaved time.Time
+}
 
-	// Fetch liked songs
-	songs, err := spotify.FetchAllLikedSongs(accessToken, func(processed, total int) {
-		job.SongsProcessed = processed
-		job.TotalSongs = total
-		updateJob()
+// update applies a change to the job and persists it, throttling saves of
+// progress-only changes
+func (r *jobRun) update(force bool, fn func(record *models.OrganizeJob)) {
+	snapshot := r.job.Update(fn)
+	if !force && time.Since(r.lastSaved) < progressSaveInterval {
+		return
+	}
+	r.lastSaved = time.Now()
+	if err := jobStore.Update(snapshot); err != nil {
+		log.Printf("organize job %s: failed to save progress: %v", r.id, err)
+	}
+}
+
+func (r *jobRun) publish(eventType string, data interface{}) {
+	jobEvents.Publish(r.id, jobs.Event{Type: eventType, Data: data})
+}
+
+func (r *jobRun) setStage(stage string) {
+	r.update(true, func(record *models.OrganizeJob) {
+		if record.Status == models.JobStatusPending {
+			record.Status = models.JobStatusProcessing
+		}
+		record.Stage = stage
 	})
-	if err != nil {
-		log.Printf("organize job %s: failed to fetch songs: %v", job.ID, err)
-		job.Status = "failed"
-		job.Error = "Failed to fetch your liked songs. Please try again."
-		updateJob()
+	r.publish(jobs.EventStage, jobs.StageEvent{Stage: stage})
+}
+
+func (r *jobRun) setProgress(processed, total int) {
+	r.update(false, func(record *models.OrganizeJob) {
+		record.SongsProcessed = processed
+		record.TotalSongs = total
+	})
+	r.publish(jobs.EventProgress, jobs.ProgressEvent{SongsProcessed: processed, TotalSongs: total})
+}
+
+// addPlaylist records a playlist the job has written
+func (r *jobRun) addPlaylist(playlist organizer.PlaylistResult) {
+	created := jobPlaylist(playlist)
+	r.update(true, func(record *models.OrganizeJob) {
+		record.PlaylistsCreated = append(record.PlaylistsCreated, created)
+	})
+	r.publish(jobs.EventPlaylist, created)
+}
+
+func (r *jobRun) finish(status, message string) {
+	r.update(true, func(record *models.OrganizeJob) {
+		now := time.Now()
+		record.Status = status
+		record.ErrorMessage = message
+		record.CompletedAt = &now
+		if status == models.JobStatusCompleted {
+			record.Stage = "done"
+		}
+	})
+}
+
+// fail records why the job stopped. A cancelled job isn't a failure, it just
+// keeps whatever it managed to do before being stopped.
+func (r *jobRun) fail(err error, message string) {
+	if errors.Is(err, context.Canceled) {
+		r.finish(models.JobStatusCancelled, "")
 		return
 	}
+	r.finish(models.JobStatusFailed, message)
+}
+
+func processOrganizeJob(ctx context.Context, run *jobRun, client *spotify.Client, userID string, req OrganizeRequest) {
+	jobID := run.id
 
-	job.Stage = "analyzing"
-	updateJob()
+	run.setStage("fetching")
 
-	// Fetch artist genres
-	artistGenres, err := spotify.FetchAllArtistGenres(accessToken, songs, nil)
+	// Bring the stored library up to date; only new songs are fetched and
+	// looked up unless the whole library is due to be fetched again
+	snapshot, _, err := library.Refresh(ctx, client, libraryStore, userID, run.setProgress)
 	if err != nil {
-		log.Printf("organize job %s: failed to fetch artist genres: %v", job.ID, err)
-		job.Status = "failed"
-		job.Error = "Failed to analyze song genres. Please try again."
-		updateJob()
+		log.Printf("organize job %s: failed to fetch songs: %v", jobID, err)
+		run.fail(err, "Failed to fetch your liked songs. Please try again.")
 		return
 	}
+	songs := snapshot.Songs
 
-	// Enrich songs with genres
-	spotify.EnrichSongsWithGenres(songs, artistGenres)
+	run.setStage("analyzing")
 
 	// Collect discovered genres for UI
 	genreSet := make(map[string]bool)
@@ -120,52 +327,349 @@ func processOrganizeJob(job *JobStatus, accessToken, userID string, req Organize
 			genreSet[g] = true
 		}
 	}
+	discovered := make([]string, 0, len(genreSet))
 	for g := range genreSet {
-		job.GenresDiscovered = append(job.GenresDiscovered, g)
+		discovered = append(discovered, g)
+	}
+	run.update(false, func(record *models.OrganizeJob) {
+		record.GenresDiscovered = discovered
+	})
+	run.publish(jobs.EventGenres, jobs.GenresEvent{GenresDiscovered: discovered})
+
+	if req.DryRun {
+		run.setStage("planning")
+		plan := organizer.PlanSongs(userID, songs, req.PlaylistCount, req.ReplaceExisting)
+		now := time.Now()
+		plan.ID = uuid.New().String()
+		plan.CreatedAt = now
+		plan.UpdatedAt = now
+		if err := planStore.Create(plan); err != nil {
+			log.Printf("organize job %s: failed to save plan: %v", jobID, err)
+			run.fail(err, "Failed to save the preview. Please try again.")
+			return
+		}
+		run.update(true, func(record *models.OrganizeJob) {
+			record.PlanID = &plan.ID
+		})
+		run.finish(models.JobStatusCompleted, "")
+		return
 	}
-	updateJob()
 
-	job.Stage = "creating"
-	updateJob()
+	run.setStage("creating")
 
 	// Organize into playlists
-	result, err := organizer.OrganizeSongs(
-		accessToken,
+	_, err = organizer.OrganizeSongs(
+		ctx,
+		client,
 		userID,
 		songs,
 		req.PlaylistCount,
 		req.ReplaceExisting,
 		func(stage string, processed, total int) {
-			job.SongsProcessed = processed
-			job.TotalSongs = total
-			updateJob()
+			run.setProgress(processed, total)
 		},
+		run.addPlaylist,
 	)
 	if err != nil {
-		log.Printf("organize job %s: failed to create playlists: %v", job.ID, err)
-		job.Status = "failed"
-		job.Error = "Failed to create playlists. Please try again."
-		updateJob()
+		log.Printf("organize job %s: failed to create playlists: %v", jobID, err)
+		run.fail(err, "Failed to create playlists. Please try again.")
 		return
 	}
 
-	job.Status = "completed"
-	job.Stage = "done"
-	job.Result = result
-	updateJob()
+	run.finish(models.JobStatusCompleted, "")
+}
+
+// ListOrganizeJobs returns the user's recent organize jobs, newest first,
+// with their parameters, outcome and the playlists they wrote
+func ListOrganizeJobs(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	records, err := jobStore.ListByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+
+	history := make([]*JobStatus, len(records))
+	for i, record := range records {
+		// Stored progress of running jobs lags behind; prefer the live state
+		jobsMu.RLock()
+		job, running := runningJobs[record.ID]
+		jobsMu.RUnlock()
+
+		if running {
+			record = job.Snapshot()
+		} else {
+			expireStaleJob(record)
+		}
+		// Previews list every track; fetch the job itself to see one
+		history[i] = jobStatusFromRecord(record)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": history})
 }
 
 func GetOrganizeStatus(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	record, err := findJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	if record == nil || record.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withPreview(jobStatusFromRecord(record)))
+}
+
+// GetOrganizeEvents streams a job's progress as Server-Sent Events. The
+// stream opens with a "status" event holding the full job status, followed by
+// "stage", "progress", "genres" and "playlist" events as they happen, and ends
+// with a "done" event holding the final status.
+func GetOrganizeEvents(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	jobID := c.Param("id")
+
+	// Subscribe before reading the job so no event between the two is lost
+	events, unsubscribe := jobEvents.Subscribe(jobID)
+	defer unsubscribe()
+
+	record, err := findJob(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	if record == nil || record.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	if record.IsFinished() {
+		send("done", withPreview(jobStatusFromRecord(record)))
+		return
+	}
+	send("status", jobStatusFromRecord(record))
+
+	jobsMu.RLock()
+	_, running := runningJobs[jobID]
+	jobsMu.RUnlock()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// Jobs running on another instance publish nothing here, so fall back
+	// to watching the store
+	var poll <-chan time.Time
+	if !running {
+		ticker := time.NewTicker(remoteJobPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	lastUpdate := record.UpdatedAt
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+
+		case event, ok := <-events:
+			if !ok {
+				// The job finished and its final state is in the store
+				if final, err := jobStore.Get(jobID); err == nil && final != nil {
+					record = final
+				}
+				send("done", withPreview(jobStatusFromRecord(record)))
+				return
+			}
+			send(event.Type, event.Data)
+
+		case <-poll:
+			latest, err := findJob(jobID)
+			if err != nil || latest == nil || latest.UpdatedAt.Equal(lastUpdate) {
+				continue
+			}
+			lastUpdate = latest.UpdatedAt
+			if latest.IsFinished() {
+				send("done", withPreview(jobStatusFromRecord(latest)))
+				return
+			}
+			send("status", jobStatusFromRecord(latest))
+
+		case <-heartbeat.C:
+			send("ping", time.Now().Unix())
+		}
+	}
+}
+
+// CancelOrganize stops a running organize job between Spotify calls. Playlists
+// created before the cancellation are kept and listed in the job's result so
+// the user can decide whether to keep them.
+func CancelOrganize(c *gin.Context) {
+	userID := sessionUserID(c)
+
 	jobID := c.Param("id")
 
 	jobsMu.RLock()
-	job, exists := jobs[jobID]
+	job, running := runningJobs[jobID]
 	jobsMu.RUnlock()
 
-	if !exists {
+	if !running {
+		// Either finished, unknown, or running on another instance
+		record, err := findJob(jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+			return
+		}
+		if record == nil || record.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		if record.IsFinished() {
+			c.JSON(http.StatusConflict, gin.H{"error": "job has already finished"})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "job is running on another server, please retry"})
+		return
+	}
+
+	if job.Snapshot().UserID != userID {
 		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, job)
+	job.cancel()
+
+	// Give the job a moment to wind down so the response can report what
+	// it created; otherwise the client can keep polling for the final state
+	select {
+	case <-job.done:
+	case <-time.After(cancelWaitTimeout):
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id": jobID,
+			"status": "cancelling",
+		})
+		return
+	}
+
+	record, err := jobStore.Get(jobID)
+	if err != nil || record == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobStatusFromRecord(record))
+}
+
+// findJob returns the live record for jobs running in this process, falling
+// back to the job store for finished jobs and jobs started elsewhere
+func findJob(jobID string) (*models.OrganizeJob, error) {
+	jobsMu.RLock()
+	job, running := runningJobs[jobID]
+	jobsMu.RUnlock()
+
+	if running {
+		return job.Snapshot(), nil
+	}
+
+	record, err := jobStore.Get(jobID)
+	if err != nil || record == nil {
+		return record, err
+	}
+
+	expireStaleJob(record)
+	return record, nil
+}
+
+// expireStaleJob marks a stored job as failed when it is unfinished and
+// nobody has touched it in a while: the process running it went away
+// (restart, crash, scale-down), so it will never complete
+func expireStaleJob(record *models.OrganizeJob) {
+	if !record.IsFinished() && time.Since(record.UpdatedAt) > staleJobTimeout {
+		now := time.Now()
+		record.Status = models.JobStatusFailed
+		record.ErrorMessage = "This job was interrupted. Please try again."
+		record.CompletedAt = &now
+		if err := jobStore.Update(record); err != nil {
+			log.Printf("organize job %s: failed to mark as interrupted: %v", record.ID, err)
+		}
+	}
+}
+
+func jobStatusFromRecord(record *models.OrganizeJob) *JobStatus {
+	status := &JobStatus{
+		ID:               record.ID,
+		PlaylistCount:    record.PlaylistCount,
+		ReplaceExisting:  record.ReplaceExisting,
+		DryRun:           record.DryRun,
+		Status:           record.Status,
+		Stage:            record.Stage,
+		SongsProcessed:   record.SongsProcessed,
+		TotalSongs:       record.TotalSongs,
+		GenresDiscovered: record.GenresDiscovered,
+		Error:            record.ErrorMessage,
+		CreatedAt:        record.CreatedAt,
+		CompletedAt:      record.CompletedAt,
+	}
+
+	if record.PlanID != nil {
+		status.PlanID = *record.PlanID
+	}
+
+	if !record.DryRun && (record.Status == models.JobStatusCompleted || len(record.PlaylistsCreated) > 0) {
+		status.Result = &organizer.OrganizeResult{Playlists: make([]organizer.PlaylistResult, len(record.PlaylistsCreated))}
+		for i, p := range record.PlaylistsCreated {
+			status.Result.Playlists[i] = organizer.PlaylistResult{
+				Name:       p.Name,
+				Genre:      p.Genre,
+				SpotifyID:  p.SpotifyID,
+				SpotifyURL: p.SpotifyURL,
+				SongCount:  p.SongCount,
+				Status:     p.Status,
+			}
+		}
+	}
+
+	return status
+}
+
+// withPreview attaches a finished dry run's plan, as currently edited, to
+// its status
+func withPreview(status *JobStatus) *JobStatus {
+	if !status.DryRun || status.PlanID == "" {
+		return status
+	}
+
+	plan, err := planStore.Get(status.PlanID)
+	if err != nil {
+		log.Printf("organize job %s: failed to load plan %s: %v", status.ID, status.PlanID, err)
+		return status
+	}
+	status.Preview = plan
+	return status
+}
+
+func jobPlaylist(p organizer.PlaylistResult) models.JobPlaylist {
+	return models.JobPlaylist{
+		Name:       p.Name,
+		Genre:      p.Genre,
+		SpotifyID:  p.SpotifyID,
+		SpotifyURL: p.SpotifyURL,
+		SongCount:  p.SongCount,
+		Status:     p.Status,
+	}
 }
@@ -0,0 +1,607 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/auth"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/library"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/organizer"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+// newOrganizeTestServer points the handlers at a fake Spotify and an
+// in-memory job store, and returns a router plus a session cookie for alice
+func newOrganizeTestServer(t *testing.T, songs int) (*gin.Engine, string) {
+	r, _, session := newOrganizeTestServerWithSpotify(t, songs)
+	return r, session
+}
+
+func newOrganizeTestServerWithSpotify(t *testing.T, songs int) (*gin.Engine, *spotifytest.Server, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	srv := spotifytest.NewServer()
+	t.Cleanup(srv.Close)
+
+	token := srv.AddUser("alice")
+	srv.SetArtistGenres("rock-artist", "indie rock")
+	srv.SetArtistGenres("jazz-artist", "jazz fusion")
+	for i := 0; i < songs; i++ {
+		artist := "rock-artist"
+		if i%3 == 0 {
+			artist = "jazz-artist"
+		}
+		srv.AddLikedSongs("alice", spotify.Song{
+			ID:      fmt.Sprintf("track%d", i),
+			Artists: []spotify.Artist{{ID: artist}},
+		})
+	}
+
+	prevConfig, prevStore, prevPlans, prevLibrary, prevTokens := spotifyConfig, jobStore, planStore, libraryStore, tokenStore
+	prevSessions, prevSessionKey := sessionStore, sessionKey
+	spotifyConfig = srv.Config()
+	SetTokenStore(nil)
+	SetSessions(nil, nil)
+	SetJobStore(jobs.NewMemoryStore())
+	SetPlanStore(jobs.NewMemoryPlanStore())
+	SetLibraryStore(library.NewMemoryStore())
+	// Every test server starts with no playlists recorded
+	organizer.SetOverrideStore(organizer.NewMemoryOverrideStore())
+	t.Cleanup(func() {
+		spotifyConfig = prevConfig
+		SetTokenStore(prevTokens)
+		SetSessions(prevSessions, prevSessionKey)
+		SetJobStore(prevStore)
+		SetPlanStore(prevPlans)
+		SetLibraryStore(prevLibrary)
+	})
+
+	_, session, err := getSessions().Create("alice", auth.Token{AccessToken: token, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(RequireSession)
+	r.POST("/api/organize", StartOrganize)
+	r.GET("/api/organize", ListOrganizeJobs)
+	r.GET("/api/organize/:id", GetOrganizeStatus)
+	r.GET("/api/organize/:id/events", GetOrganizeEvents)
+	r.DELETE("/api/organize/:id", CancelOrganize)
+	r.GET("/api/organize/plans/:id", GetOrganizePlan)
+	r.PATCH("/api/organize/plans/:id", EditOrganizePlan)
+	r.POST("/api/organize/plans/:id/apply", ApplyOrganizePlan)
+	r.POST("/api/playlists/sync-all", SyncAllPlaylists)
+	return r, srv, session
+}
+
+func doRequest(r http.Handler, method, path, body, session string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// waitForJob makes sure the job has fully stopped before the test's cleanup
+// swaps the job store back
+func waitForJob(t *testing.T, jobID string) {
+	jobsMu.RLock()
+	job, running := runningJobs[jobID]
+	jobsMu.RUnlock()
+
+	if running {
+		t.Cleanup(func() { <-job.done })
+	}
+}
+
+// finishJob waits for a job running in this process to finish
+func finishJob(jobID string) {
+	jobsMu.RLock()
+	job, running := runningJobs[jobID]
+	jobsMu.RUnlock()
+
+	if running {
+		<-job.done
+	}
+}
+
+// Run with -race: status reads overlap the job's progress updates
+func TestGetOrganizeStatusWhileJobRuns(t *testing.T) {
+	r, session := newOrganizeTestServer(t, 250)
+
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 2}`, session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("StartOrganize: %d %s", w.Code, w.Body)
+	}
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	waitForJob(t, started.JobID)
+
+	var wg sync.WaitGroup
+	final := make(chan JobStatus, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deadline := time.Now().Add(10 * time.Second)
+			for time.Now().Before(deadline) {
+				w := doRequest(r, "GET", "/api/organize/"+started.JobID, "", session)
+				if w.Code != http.StatusOK {
+					t.Errorf("GetOrganizeStatus: %d %s", w.Code, w.Body)
+					return
+				}
+				var status JobStatus
+				if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+					t.Errorf("decode status: %v", err)
+					return
+				}
+				if status.Status != models.JobStatusPending && status.Status != models.JobStatusProcessing {
+					final <- status
+					return
+				}
+			}
+			t.Error("job did not finish in time")
+		}()
+	}
+	wg.Wait()
+	close(final)
+
+	for status := range final {
+		if status.Status != models.JobStatusCompleted {
+			t.Fatalf("expected completed job, got %+v", status)
+		}
+		if status.Result == nil || len(status.Result.Playlists) != 2 {
+			t.Fatalf("expected 2 playlists in result, got %+v", status.Result)
+		}
+	}
+}
+
+func TestStartOrganizeReturnsExistingJob(t *testing.T) {
+	r, session := newOrganizeTestServer(t, 10)
+
+	prevScheduler := jobScheduler
+	jobScheduler = jobs.NewScheduler(1)
+	t.Cleanup(func() { jobScheduler = prevScheduler })
+
+	// Occupy the only worker with someone else's job so alice's queues
+	blocker, _ := jobScheduler.Reserve("bob", jobs.ActiveJob{ID: "blocker", Kind: jobs.KindSync})
+	release := make(chan struct{})
+	blockerDone := blocker.Start(context.Background(), func(ctx context.Context) { <-release })
+
+	var first, second struct {
+		JobID    string `json:"job_id"`
+		Status   string `json:"status"`
+		Existing bool   `json:"existing"`
+	}
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 2}`, session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("StartOrganize: %d %s", w.Code, w.Body)
+	}
+	json.Unmarshal(w.Body.Bytes(), &first)
+	waitForJob(t, first.JobID)
+
+	w = doRequest(r, "POST", "/api/organize", `{"playlist_count": 3}`, session)
+	json.Unmarshal(w.Body.Bytes(), &second)
+	if w.Code != http.StatusOK || !second.Existing || second.JobID != first.JobID {
+		t.Errorf("expected duplicate to return queued job %s, got %d %s", first.JobID, w.Code, w.Body)
+	}
+	if second.Status != models.JobStatusPending {
+		t.Errorf("expected queued job to be pending, got %q", second.Status)
+	}
+
+	w = doRequest(r, "POST", "/api/playlists/sync-all", "", session)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), first.JobID) {
+		t.Errorf("expected sync to conflict with the organize job, got %d %s", w.Code, w.Body)
+	}
+
+	close(release)
+	<-blockerDone
+}
+
+func TestListOrganizeJobs(t *testing.T) {
+	r, session := newOrganizeTestServer(t, 10)
+
+	// A finished run, plus one whose server died mid-run an hour ago
+	finishedAt := time.Now().Add(-2 * time.Hour)
+	jobStore.Create(&models.OrganizeJob{
+		ID:               "old",
+		UserID:           "alice",
+		PlaylistCount:    5,
+		ReplaceExisting:  true,
+		Status:           models.JobStatusCompleted,
+		PlaylistsCreated: []models.JobPlaylist{{Name: "Rock by Organizer", Genre: "Rock", SongCount: 3}},
+		CreatedAt:        finishedAt.Add(-time.Minute),
+		CompletedAt:      &finishedAt,
+	})
+	jobStore.Create(&models.OrganizeJob{
+		ID:        "abandoned",
+		UserID:    "alice",
+		Status:    models.JobStatusProcessing,
+		CreatedAt: time.Now().Add(-time.Hour),
+		UpdatedAt: time.Now().Add(-time.Hour),
+	})
+	jobStore.Create(&models.OrganizeJob{ID: "bobs", UserID: "bob", CreatedAt: time.Now()})
+
+	w := doRequest(r, "GET", "/api/organize", "", session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ListOrganizeJobs: %d %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Jobs []JobStatus `json:"jobs"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if len(resp.Jobs) != 2 || resp.Jobs[0].ID != "abandoned" || resp.Jobs[1].ID != "old" {
+		t.Fatalf("expected alice's 2 jobs newest first, got %+v", resp.Jobs)
+	}
+	if resp.Jobs[0].Status != models.JobStatusFailed {
+		t.Errorf("expected abandoned job to be reported as failed, got %q", resp.Jobs[0].Status)
+	}
+
+	old := resp.Jobs[1]
+	if old.PlaylistCount != 5 || !old.ReplaceExisting || old.CompletedAt == nil {
+		t.Errorf("expected parameters and completion time, got %+v", old)
+	}
+	if old.Result == nil || len(old.Result.Playlists) != 1 || old.Result.Playlists[0].Genre != "Rock" {
+		t.Errorf("expected created playlists in result, got %+v", old.Result)
+	}
+}
+
+func TestCancelOrganize(t *testing.T) {
+	r, srv, session := newOrganizeTestServerWithSpotify(t, 10)
+	_, bob, err := getSessions().Create("bob", auth.Token{AccessToken: srv.AddUser("bob"), ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	prevScheduler := jobScheduler
+	jobScheduler = jobs.NewScheduler(1)
+	t.Cleanup(func() { jobScheduler = prevScheduler })
+
+	// Keep alice's job queued behind someone else's until it is cancelled
+	blocker, _ := jobScheduler.Reserve("carol", jobs.ActiveJob{ID: "blocker", Kind: jobs.KindSync})
+	blocking, release := make(chan struct{}), make(chan struct{})
+	blockerDone := blocker.Start(context.Background(), func(ctx context.Context) {
+		close(blocking)
+		<-release
+	})
+	<-blocking
+	defer func() {
+		close(release)
+		<-blockerDone
+	}()
+
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 2}`, session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("StartOrganize: %d %s", w.Code, w.Body)
+	}
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	waitForJob(t, started.JobID)
+
+	if w := doRequest(r, "DELETE", "/api/organize/"+started.JobID, "", bob); w.Code != http.StatusNotFound {
+		t.Errorf("expected bob cancelling alice's job to get 404, got %d %s", w.Code, w.Body)
+	}
+
+	w = doRequest(r, "DELETE", "/api/organize/"+started.JobID, "", session)
+	var cancelled JobStatus
+	json.Unmarshal(w.Body.Bytes(), &cancelled)
+	if w.Code != http.StatusOK || cancelled.Status != models.JobStatusCancelled {
+		t.Fatalf("expected the job to be cancelled, got %d %s", w.Code, w.Body)
+	}
+
+	w = doRequest(r, "GET", "/api/organize/"+started.JobID, "", session)
+	var status JobStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if status.Status != models.JobStatusCancelled || status.CompletedAt == nil {
+		t.Errorf("expected the job to stay cancelled, got %+v", status)
+	}
+
+	// Once finished, the job is served from the store
+	if w := doRequest(r, "DELETE", "/api/organize/"+started.JobID, "", session); w.Code != http.StatusConflict {
+		t.Errorf("expected cancelling a finished job to conflict, got %d %s", w.Code, w.Body)
+	}
+	if w := doRequest(r, "DELETE", "/api/organize/"+started.JobID, "", bob); w.Code != http.StatusNotFound {
+		t.Errorf("expected bob cancelling alice's finished job to get 404, got %d %s", w.Code, w.Body)
+	}
+	if w := doRequest(r, "DELETE", "/api/organize/unknown", "", session); w.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown job to get 404, got %d %s", w.Code, w.Body)
+	}
+}
+
+// readEvent reads the next server-sent event from body, skipping pings
+func readEvent(t *testing.T, body *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(line, "data:")
+		case line == "" && event == "ping":
+			event, data = "", ""
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestGetOrganizeEvents(t *testing.T) {
+	r, session := newOrganizeTestServer(t, 10)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	prevScheduler := jobScheduler
+	jobScheduler = jobs.NewScheduler(1)
+	t.Cleanup(func() { jobScheduler = prevScheduler })
+
+	// Keep alice's job queued until the streams are open
+	blocker, _ := jobScheduler.Reserve("bob", jobs.ActiveJob{ID: "blocker", Kind: jobs.KindSync})
+	blocking, release := make(chan struct{}), make(chan struct{})
+	blockerDone := blocker.Start(context.Background(), func(ctx context.Context) {
+		close(blocking)
+		<-release
+	})
+	<-blocking
+
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 2}`, session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("StartOrganize: %d %s", w.Code, w.Body)
+	}
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	waitForJob(t, started.JobID)
+
+	stream := func(ctx context.Context) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/organize/"+started.JobID+"/events", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GetOrganizeEvents: %v", err)
+		}
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	resp, body := stream(context.Background())
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GetOrganizeEvents: %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Errorf("expected an event stream, got %q", got)
+	}
+	for header, want := range map[string]string{"Cache-Control": "no-cache", "X-Accel-Buffering": "no"} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("expected %s %q, got %q", header, want, got)
+		}
+	}
+
+	event, data := readEvent(t, body)
+	var snapshot JobStatus
+	json.Unmarshal([]byte(data), &snapshot)
+	if event != "status" || snapshot.ID != started.JobID || snapshot.Status != models.JobStatusPending {
+		t.Fatalf("expected the queued job as the first event, got %s %s", event, data)
+	}
+
+	// A client that goes away stops being sent events
+	ctx, disconnect := context.WithCancel(context.Background())
+	other, otherBody := stream(ctx)
+	readEvent(t, otherBody)
+	if n := jobEvents.Subscribers(started.JobID); n != 2 {
+		t.Fatalf("expected both streams to be subscribed, got %d", n)
+	}
+	disconnect()
+	other.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for jobEvents.Subscribers(started.JobID) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the disconnected stream to unsubscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	<-blockerDone
+
+	for event != "done" {
+		event, data = readEvent(t, body)
+	}
+	var final JobStatus
+	json.Unmarshal([]byte(data), &final)
+	if final.Status != models.JobStatusCompleted || final.Result == nil || len(final.Result.Playlists) != 2 {
+		t.Errorf("expected the completed job as the last event, got %s", data)
+	}
+	if _, err := body.ReadByte(); err != io.EOF {
+		t.Errorf("expected the stream to end once the job finished, got %v", err)
+	}
+	if n := jobEvents.Subscribers(started.JobID); n != 0 {
+		t.Errorf("expected no subscribers left, got %d", n)
+	}
+}
+
+func TestStartOrganizeDryRun(t *testing.T) {
+	r, srv, session := newOrganizeTestServerWithSpotify(t, 30)
+
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 1, "dry_run": true}`, session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("StartOrganize: %d %s", w.Code, w.Body)
+	}
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	finishJob(started.JobID)
+
+	w = doRequest(r, "GET", "/api/organize/"+started.JobID, "", session)
+	var status JobStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+
+	if status.Status != models.JobStatusCompleted || !status.DryRun || status.Result != nil {
+		t.Fatalf("expected completed dry run without result, got %+v", status)
+	}
+	preview := status.Preview
+	if preview == nil || preview.ID != status.PlanID || len(preview.Playlists) != 1 {
+		t.Fatalf("expected a 1-playlist preview, got %+v", preview)
+	}
+	if preview.Playlists[0].Genre != "Rock" || len(preview.Playlists[0].Tracks) != 20 {
+		t.Errorf("expected Rock with 20 tracks, got %s with %d", preview.Playlists[0].Genre, len(preview.Playlists[0].Tracks))
+	}
+	if len(preview.MergedIntoOther) != 1 || preview.MergedIntoOther[0].Genre != "Jazz" || preview.UnplacedTracks != 10 {
+		t.Errorf("expected Jazz merged away with 10 unplaced tracks, got %+v / %d", preview.MergedIntoOther, preview.UnplacedTracks)
+	}
+
+	for _, req := range srv.Requests() {
+		if !strings.HasPrefix(req, "GET ") {
+			t.Errorf("dry run made a mutating Spotify call: %s", req)
+		}
+	}
+}
+
+func TestApplyEditedPlan(t *testing.T) {
+	r, srv, session := newOrganizeTestServerWithSpotify(t, 30)
+
+	w := doRequest(r, "POST", "/api/organize", `{"playlist_count": 2, "dry_run": true}`, session)
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	finishJob(started.JobID)
+
+	w = doRequest(r, "GET", "/api/organize/"+started.JobID, "", session)
+	var status JobStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if status.PlanID == "" {
+		t.Fatalf("expected the dry run to produce a plan, got %+v", status)
+	}
+	planPath := "/api/organize/plans/" + status.PlanID
+
+	w = doRequest(r, "PATCH", planPath, `{"operations": [{"op": "drop_genre", "genre": "Nope"}]}`, session)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid edit to be rejected, got %d %s", w.Code, w.Body)
+	}
+
+	w = doRequest(r, "PATCH", planPath, `{"operations": [
+		{"op": "move_tracks", "track_ids": ["track0", "track3"], "to_genre": "Rock"},
+		{"op": "drop_genre", "genre": "Jazz"},
+		{"op": "rename", "genre": "Rock", "name": "Mostly Rock"}
+	]}`, session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("EditOrganizePlan: %d %s", w.Code, w.Body)
+	}
+
+	w = doRequest(r, "POST", planPath+"/apply", "", session)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("ApplyOrganizePlan: %d %s", w.Code, w.Body)
+	}
+	var applied struct {
+		JobID string `json:"job_id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &applied)
+	finishJob(applied.JobID)
+
+	playlists := srv.Playlists("alice")
+	if len(playlists) != 1 || playlists[0].Name != "Mostly Rock" || len(playlists[0].Tracks) != 22 {
+		t.Fatalf("expected one 22-track \"Mostly Rock\" playlist, got %+v", playlists)
+	}
+
+	w = doRequest(r, "GET", planPath, "", session)
+	var plan models.OrganizePlan
+	json.Unmarshal(w.Body.Bytes(), &plan)
+	if plan.Status != models.PlanStatusApplied || plan.Playlists[0].TargetPlaylistID != playlists[0].ID {
+		t.Errorf("expected the plan applied and pinned to %s, got %s / %q", playlists[0].ID, plan.Status, plan.Playlists[0].TargetPlaylistID)
+	}
+
+	w = doRequest(r, "POST", planPath+"/apply", "", session)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected applying twice to conflict, got %d %s", w.Code, w.Body)
+	}
+}
+
+// racingPlanStore lets another replica start applying a plan right after
+// each request reads it
+type racingPlanStore struct {
+	*jobs.MemoryPlanStore
+}
+
+func (s racingPlanStore) Get(planID string) (*models.OrganizePlan, error) {
+	plan, err := s.MemoryPlanStore.Get(planID)
+	if err != nil || plan == nil {
+		return plan, err
+	}
+
+	other := jobs.CopyPlan(plan)
+	other.Status = models.PlanStatusApplying
+	if err := s.MemoryPlanStore.Update(other); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func TestPlanChangesConflictAcrossReplicas(t *testing.T) {
+	r, srv, session := newOrganizeTestServerWithSpotify(t, 3)
+
+	store := racingPlanStore{jobs.NewMemoryPlanStore()}
+	SetPlanStore(store)
+	plan := &models.OrganizePlan{
+		ID:     "plan",
+		UserID: "alice",
+		Status: models.PlanStatusDraft,
+		Playlists: []models.PlannedPlaylist{
+			{Genre: "Rock", Name: "Rock", Action: models.PlanActionCreate, Tracks: []models.PlannedTrack{{ID: "track1"}}},
+		},
+	}
+	if err := store.Create(plan); err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+
+	w := doRequest(r, "PATCH", "/api/organize/plans/plan", `{"operations": [{"op": "rename", "genre": "Rock", "name": "Mine"}]}`, session)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected an edit racing an apply to conflict, got %d %s", w.Code, w.Body)
+	}
+
+	// Reset the plan to draft for the apply attempt
+	current, _ := store.MemoryPlanStore.Get("plan")
+	current.Status = models.PlanStatusDraft
+	store.MemoryPlanStore.Update(current)
+
+	w = doRequest(r, "POST", "/api/organize/plans/plan/apply", "", session)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected a second apply to conflict, got %d %s", w.Code, w.Body)
+	}
+	if _, busy := jobScheduler.Active("alice"); busy {
+		t.Errorf("expected the conflicting apply to release alice's job slot")
+	}
+	if len(srv.Playlists("alice")) != 0 {
+		t.Errorf("expected nothing written to Spotify")
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/organizer"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+type EditPlanRequest struct {
+	Operations []organizer.PlanEdit `json:"operations" binding:"required,min=1"`
+}
+
+// planStore persists plans. Its updates only apply to the version of a plan
+// they read, so an edit can't slip in while the plan starts being applied,
+// even on another replica.
+var planStore jobs.PlanStore = jobs.NewDatabasePlanStore()
+
+// SetPlanStore replaces where organize plans are persisted, e.g. with an
+// in-memory store when no database is configured
+func SetPlanStore(store jobs.PlanStore) {
+	planStore = store
+}
+
+// GetOrganizePlan returns a plan produced by a dry run, as currently edited
+func GetOrganizePlan(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	plan, ok := findPlan(c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// EditOrganizePlan applies a list of edits to a plan: moving tracks between
+// genres, dropping genres, renaming playlists and changing what applying a
+// playlist does. Either every edit is applied or none is.
+func EditOrganizePlan(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	var req EditPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, ok := findPlan(c, userID)
+	if !ok {
+		return
+	}
+
+	if !plan.IsEditable() {
+		c.JSON(http.StatusConflict, gin.H{"error": "plan can no longer be edited", "status": plan.Status})
+		return
+	}
+
+	if err := organizer.ApplyEdits(plan, req.Operations); err != nil {
+		if errors.Is(err, organizer.ErrInvalidEdit) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to edit plan"})
+		return
+	}
+
+	if err := planStore.Update(plan); err != nil {
+		if errors.Is(err, jobs.ErrPlanChanged) {
+			respondPlanChanged(c)
+			return
+		}
+		log.Printf("failed to save plan %s: %v", plan.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ApplyOrganizePlan starts a job writing the plan's playlists to Spotify. The
+// job is followed like any organize job; the plan can't be edited while it
+// runs.
+func ApplyOrganizePlan(c *gin.Context) {
+	userID := sessionUserID(c)
+
+	plan, ok := findPlan(c, userID)
+	if !ok {
+		return
+	}
+
+	if !plan.IsEditable() {
+		c.JSON(http.StatusConflict, gin.H{"error": "plan has already been applied", "status": plan.Status})
+		return
+	}
+
+	if len(plan.Playlists) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "plan has no playlists to apply"})
+		return
+	}
+
+	jobID := uuid.New().String()
+	reservation, active := jobScheduler.Reserve(userID, jobs.ActiveJob{ID: jobID, Kind: jobs.KindOrganize})
+	if reservation == nil {
+		respondJobBusy(c, active)
+		return
+	}
+
+	// Only one request, on any replica, gets to move the plan it read on
+	// from draft or failed
+	previousStatus := plan.Status
+	plan.Status = models.PlanStatusApplying
+	plan.ApplyJobID = &jobID
+	if err := planStore.Update(plan); err != nil {
+		reservation.Release()
+		if errors.Is(err, jobs.ErrPlanChanged) {
+			respondPlanChanged(c)
+			return
+		}
+		log.Printf("failed to mark plan %s as applying: %v", plan.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply plan"})
+		return
+	}
+
+	now := time.Now()
+	record := &models.OrganizeJob{
+		ID:              jobID,
+		UserID:          userID,
+		PlaylistCount:   len(plan.Playlists),
+		ReplaceExisting: plan.ReplaceExisting,
+		PlanID:          &plan.ID,
+		Status:          models.JobStatusPending,
+		Stage:           "queued",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := jobStore.Create(record); err != nil {
+		reservation.Release()
+		plan.Status = previousStatus
+		if err := planStore.Update(plan); err != nil {
+			log.Printf("failed to restore plan %s: %v", plan.ID, err)
+		}
+		log.Printf("failed to create apply job for plan %s: %v", plan.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply plan"})
+		return
+	}
+
+	// The job owns the plan from here on
+	planID := plan.ID
+	launchJob(record, reservation, sessionClient(c), func(ctx context.Context, run *jobRun, client *spotify.Client) {
+		processApplyPlan(ctx, run, client, plan)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":  jobID,
+		"plan_id": planID,
+		"status":  models.JobStatusPending,
+	})
+}
+
+func processApplyPlan(ctx context.Context, run *jobRun, client *spotify.Client, plan *models.OrganizePlan) {
+	run.setStage("creating")
+
+	result, err := organizer.ApplyPlan(
+		ctx,
+		client,
+		plan.UserID,
+		plan,
+		func(stage string, processed, total int) {
+			run.setProgress(processed, total)
+		},
+		run.addPlaylist,
+	)
+
+	status := models.PlanStatusApplied
+	if err != nil {
+		status = models.PlanStatusFailed
+	}
+	finishPlan(plan, status, result)
+
+	if err != nil {
+		log.Printf("organize job %s: failed to apply plan %s: %v", run.id, plan.ID, err)
+		run.fail(err, "Failed to create playlists. Please try again.")
+		return
+	}
+
+	run.finish(models.JobStatusCompleted, "")
+}
+
+// finishPlan saves the outcome of applying a plan. Playlists that were
+// written are pinned to the Spotify playlist, so applying a failed plan again
+// updates them instead of creating duplicates.
+func finishPlan(plan *models.OrganizePlan, status string, result *organizer.OrganizeResult) {
+	if result != nil {
+		written := make(map[string]string, len(result.Playlists))
+		for _, p := range result.Playlists {
+			written[p.Genre] = p.SpotifyID
+		}
+		for i := range plan.Playlists {
+			planned := &plan.Playlists[i]
+			if id, ok := written[planned.Genre]; ok {
+				planned.TargetPlaylistID = id
+				if planned.Action == models.PlanActionCreate {
+					planned.Action = models.PlanActionReplace
+				}
+			}
+		}
+	}
+
+	plan.Status = status
+	if err := planStore.Update(plan); err != nil {
+		log.Printf("failed to save outcome of plan %s: %v", plan.ID, err)
+	}
+}
+
+// respondPlanChanged tells the client the plan changed under it, e.g. because
+// another request started applying it, and has to be fetched again
+func respondPlanChanged(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"error": "plan was changed by another request, reload it and try again"})
+}
+
+// findPlan loads the user's plan named in the URL, responding with an error
+// and returning false if there is none
+func findPlan(c *gin.Context, userID string) (*models.OrganizePlan, bool) {
+	plan, err := planStore.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get plan"})
+		return nil, false
+	}
+
+	if plan == nil || plan.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return nil, false
+	}
+
+	expireStalePlan(plan)
+	return plan, true
+}
+
+// expireStalePlan marks a plan as failed when the job applying it ended
+// without saving the outcome, e.g. because the process running it died, so
+// the plan can be applied again
+func expireStalePlan(plan *models.OrganizePlan) {
+	if plan.Status != models.PlanStatusApplying || plan.ApplyJobID == nil {
+		return
+	}
+
+	record, err := findJob(*plan.ApplyJobID)
+	if err != nil || (record != nil && !record.IsFinished()) {
+		return
+	}
+
+	plan.Status = models.PlanStatusFailed
+	if err := planStore.Update(plan); err != nil {
+		log.Printf("plan %s: failed to mark as interrupted: %v", plan.ID, err)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
@@ -9,7 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/spotify-genre-organizer/backend/internal/database"
 	"github.com/spotify-genre-organizer/backend/internal/genres"
+	"github.com/spotify-genre-organizer/backend/internal/library"
 	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/playlistsync"
 	"github.com/spotify-genre-organizer/backend/internal/spotify"
 )
 
@@ -26,19 +29,16 @@ type ManagedPlaylist struct {
 	CustomName *string `json:"custom_name"`
 	CustomDesc *string `json:"custom_description"`
 	LastSynced *string `json:"last_synced"`
+	SyncPolicy string  `json:"sync_policy"`
 }
 
 func ListPlaylists(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
-
-	userID, _ := c.Cookie("user_id")
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
 	// Fetch all user's playlists from Spotify
-	playlists, err := spotify.GetUserPlaylists(accessToken)
+	playlists, err := client.GetUserPlaylists(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch playlists"})
 		return
@@ -51,27 +51,19 @@ func ListPlaylists(c *gin.Context) {
 		return
 	}
 
-	// Debug: Check which user the access token belongs to
-	profile, profileErr := spotify.GetUserProfile(accessToken)
-	if profileErr == nil {
-		log.Printf("DEBUG: Access token belongs to user: %s (%s)", profile.ID, profile.DisplayName)
-	} else {
-		log.Printf("DEBUG: Could not fetch profile: %v", profileErr)
+	// Sync policies live in the overrides; playlists without one mirror
+	overrides, err := database.GetPlaylistOverrides(userID)
+	if err != nil {
+		log.Printf("Failed to load playlist overrides for %s: %v", userID, err)
 	}
-	log.Printf("DEBUG: User ID from cookie: %s", userID)
-	log.Printf("DEBUG: Found %d total playlists from Spotify", len(playlists))
-	log.Printf("DEBUG: User template: %s", settings.NameTemplate)
 
 	// Filter to only Organizer-created playlists
 	var managed []ManagedPlaylist
 	for _, p := range playlists {
-		log.Printf("DEBUG: Checking playlist: '%s'", p.Name)
 		// Check if matches our naming pattern (contains "by Organizer" or template pattern)
 		if !isOrganizerPlaylist(p.Name, settings.NameTemplate) {
-			log.Printf("DEBUG: SKIPPED - doesn't match pattern")
 			continue
 		}
-		log.Printf("DEBUG: MATCHED!")
 
 		genre := extractGenreFromName(p.Name, settings.NameTemplate)
 
@@ -81,6 +73,10 @@ func ListPlaylists(c *gin.Context) {
 			Genre:      genre,
 			SongCount:  p.Tracks.Total,
 			SpotifyURL: p.ExternalURLs.Spotify,
+			SyncPolicy: models.SyncPolicyMirror,
+		}
+		if override, ok := overrides[p.ID]; ok {
+			mp.SyncPolicy = override.Policy()
 		}
 
 		if len(p.Images) > 0 {
@@ -156,33 +152,93 @@ func getPlaylistOverride(userID, playlistID string) *models.PlaylistOverride {
 	return overrides[playlistID]
 }
 
-func savePlaylistOverride(override *models.PlaylistOverride) {
-	database.SavePlaylistOverride(override)
+func savePlaylistOverride(override *models.PlaylistOverride) error {
+	if err := database.SavePlaylistOverride(override); err != nil {
+		log.Printf("Failed to save playlist override for %s: %v", override.PlaylistSpotifyID, err)
+		return err
+	}
+	return nil
 }
 
 func deletePlaylistOverride(userID, playlistID string) {
-	// Not strictly deleting row, but could implement real delete if needed.
-	// For now, let's just assume modifying logic or leave it as is if we implemented delete in DB.
-	// But our DB helper was Upsert. We probably need a Delete function in DB helper.
-	// For MVP, let's skip explicit delete implementation in DB helper if not requested,
-	// or implemented a soft delete by setting genre to empty?
-	// Actually, let's just ignoring implementation detail for now or add Delete to DB.
-	// Let's assume we implement DeletePlaylistOverrides in database/settings.go next.
+	if err := database.DeletePlaylistOverride(userID, playlistID); err != nil {
+		log.Printf("Failed to delete playlist override for %s: %v", playlistID, err)
+	}
+}
+
+// PlaylistDetail describes one playlist, with the tracks sync manages listed
+// apart from the ones the user added, which sync keeps
+type PlaylistDetail struct {
+	SpotifyID  string     `json:"spotify_id"`
+	Name       string     `json:"name"`
+	Genre      string     `json:"genre"`
+	SpotifyURL string     `json:"spotify_url"`
+	SyncPolicy string     `json:"sync_policy"`
+	LastSynced *time.Time `json:"last_synced"`
+	Tracks     []string   `json:"tracks"`
+	UserTracks []string   `json:"user_tracks"`
+}
+
+func GetPlaylist(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
+
+	playlistID := c.Param("id")
+
+	playlist, err := client.GetPlaylist(ctx, playlistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch playlist"})
+		return
+	}
+	if playlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "playlist not found"})
+		return
+	}
+
+	trackIDs, err := client.GetPlaylistTrackIDs(ctx, playlistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch playlist tracks"})
+		return
+	}
+
+	detail := PlaylistDetail{
+		SpotifyID:  playlist.ID,
+		Name:       playlist.Name,
+		SpotifyURL: playlist.ExternalURL,
+		SyncPolicy: models.SyncPolicyMirror,
+	}
+
+	var recorded []string
+	if override := getPlaylistOverride(userID, playlistID); override != nil {
+		detail.Genre = override.Genre
+		detail.SyncPolicy = override.Policy()
+		detail.LastSynced = override.LastSyncedAt
+		recorded = override.ManagedTracks
+	}
+
+	detail.Tracks, detail.UserTracks = playlistsync.Ownership(trackIDs, recorded)
+	if detail.Tracks == nil {
+		detail.Tracks = []string{}
+	}
+	if detail.UserTracks == nil {
+		detail.UserTracks = []string{}
+	}
+
+	c.JSON(http.StatusOK, detail)
 }
 
 type UpdatePlaylistRequest struct {
 	CustomName        *string `json:"custom_name"`
 	CustomDescription *string `json:"custom_description"`
+	SyncPolicy        *string `json:"sync_policy"`
 }
 
 func UpdatePlaylist(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
-	userID, _ := c.Cookie("user_id")
 	playlistID := c.Param("id")
 
 	var req UpdatePlaylistRequest
@@ -191,6 +247,11 @@ func UpdatePlaylist(c *gin.Context) {
 		return
 	}
 
+	if req.SyncPolicy != nil && !models.IsValidSyncPolicy(*req.SyncPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sync policy must be mirror, append_only or frozen"})
+		return
+	}
+
 	// Build update values
 	newName := ""
 	newDesc := ""
@@ -210,7 +271,7 @@ func UpdatePlaylist(c *gin.Context) {
 
 	// Update in Spotify
 	if newName != "" || newDesc != "" {
-		if err := spotify.UpdatePlaylistDetails(accessToken, playlistID, newName, newDesc); err != nil {
+		if err := client.UpdatePlaylistDetails(ctx, playlistID, newName, newDesc); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update playlist"})
 			return
 		}
@@ -230,23 +291,26 @@ func UpdatePlaylist(c *gin.Context) {
 	if req.CustomDescription != nil {
 		override.CustomDescription = req.CustomDescription
 	}
-	savePlaylistOverride(override)
+	if req.SyncPolicy != nil {
+		override.SyncPolicy = *req.SyncPolicy
+	}
+	if err := savePlaylistOverride(override); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save playlist settings"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 func DeletePlaylist(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
-	userID, _ := c.Cookie("user_id")
 	playlistID := c.Param("id")
 
 	// Unfollow (delete) the playlist in Spotify
-	if err := spotify.UnfollowPlaylist(accessToken, playlistID); err != nil {
+	if err := client.UnfollowPlaylist(ctx, playlistID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete playlist"})
 		return
 	}
@@ -258,31 +322,38 @@ func DeletePlaylist(c *gin.Context) {
 }
 
 func RefreshPlaylist(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
+	userID := sessionUserID(c)
+	client := sessionClient(c)
+
+	playlistID := c.Param("id")
+
+	runSyncJob(c, userID, func(ctx context.Context) {
+		refreshPlaylist(ctx, c, client, userID, playlistID)
+	})
+}
+
+func refreshPlaylist(ctx context.Context, c *gin.Context, client *spotify.Client, userID, playlistID string) {
+	settings, err := database.GetUserSettings(userID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settings"})
 		return
 	}
 
-	userID, _ := c.Cookie("user_id")
-	playlistID := c.Param("id")
+	if isUnlikedArchive(settings, playlistID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the Unliked playlist isn't synced with a genre"})
+		return
+	}
 
 	// Get the playlist's genre from our override store
 	override := getPlaylistOverride(userID, playlistID)
 	if override == nil || override.Genre == "" {
 		// Try to get genre from the playlist name
-		playlists, err := spotify.GetUserPlaylists(accessToken)
+		playlists, err := client.GetUserPlaylists(ctx)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch playlists"})
 			return
 		}
 
-		settings, err := database.GetUserSettings(userID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settings"})
-			return
-		}
-
 		var foundGenre string
 		for _, p := range playlists {
 			if p.ID == playlistID {
@@ -306,60 +377,68 @@ func RefreshPlaylist(c *gin.Context) {
 		} else {
 			override.Genre = foundGenre
 		}
-		savePlaylistOverride(override)
+		if err := savePlaylistOverride(override); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save playlist genre"})
+			return
+		}
 	}
 
-	// Fetch all liked songs
-	songs, err := spotify.FetchAllLikedSongs(accessToken, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch songs"})
+	if override.Policy() == models.SyncPolicyFrozen {
+		c.JSON(http.StatusConflict, gin.H{"error": "playlist is frozen"})
 		return
 	}
 
-	// Enrich with genres
-	artistGenres, err := spotify.FetchAllArtistGenres(accessToken, songs, nil)
+	// Bring the stored library up to date
+	snapshot, _, err := library.Refresh(ctx, client, libraryStore, userID, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch artist genres"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch songs"})
 		return
 	}
-	spotify.EnrichSongsWithGenres(songs, artistGenres)
 
 	// Filter to songs matching this genre
-	var genreSongs []spotify.Song
-	for _, song := range songs {
-		if genres.ScoreGenres(song.Genres) == override.Genre {
-			genreSongs = append(genreSongs, song)
-		}
+	genreSongs := snapshot.ByParentGenre()[override.Genre]
+
+	// Apply only what changed, so remaining tracks keep their place
+	trackIDs := make([]string, len(genreSongs))
+	for i, s := range genreSongs {
+		trackIDs[i] = s.ID
 	}
 
-	// Clear the playlist
-	if err := spotify.ClearPlaylist(accessToken, playlistID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear playlist"})
+	result, err := playlistsync.Sync(ctx, client, playlistID, trackIDs, policyOptions(unlikedOptions(snapshot, settings), override))
+	if err != nil {
+		log.Printf("refresh of playlist %s failed: %v", playlistID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update playlist tracks"})
 		return
 	}
 
-	// Add tracks back
-	trackIDs := make([]string, len(genreSongs))
-	for i, s := range genreSongs {
-		trackIDs[i] = s.ID
+	saveErr := saveSyncState(override, result, time.Now())
+	if saveErr != nil {
+		log.Printf("refresh of playlist %s for %s: failed to save what sync added: %v", playlistID, userID, saveErr)
 	}
 
-	if len(trackIDs) > 0 {
-		if err := spotify.AddTracksToPlaylist(accessToken, playlistID, trackIDs); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add tracks"})
+	archived := 0
+	if settings.UnlikedTracks == models.UnlikedArchive && len(result.Unliked) > 0 {
+		archive, err := archiveUnliked(ctx, client, settings, result.Unliked)
+		if err != nil {
+			log.Printf("refresh of playlist %s: failed to archive %d unliked tracks: %v", playlistID, len(result.Unliked), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to archive unliked tracks"})
 			return
 		}
+		archived = len(archive.Added)
 	}
 
-	// Update last_synced_at
-	now := time.Now()
-	override.LastSyncedAt = &now
-	savePlaylistOverride(override)
+	if saveErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save playlist sync state"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
 		"song_count": len(genreSongs),
+		"added":      result.Added,
+		"removed":    result.Removed,
+		"unliked":    result.Unliked,
+		"user_owned": result.UserOwned,
+		"archived":   archived,
 	})
 }
-
-
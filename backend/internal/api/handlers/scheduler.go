@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+)
+
+// maxConcurrentJobs bounds how many organize/sync jobs run at once across
+// all users; further jobs queue until a worker frees up
+const maxConcurrentJobs = 4
+
+// jobScheduler serialises playlist-modifying work per user
+var jobScheduler = jobs.NewScheduler(maxConcurrentJobs)
+
+// respondJobBusy reports that the user already has a job modifying their
+// playlists
+func respondJobBusy(c *gin.Context, active jobs.ActiveJob) {
+	message := "an organize job is already running"
+	if active.Kind == jobs.KindSync {
+		message = "a playlist sync is already running"
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":  message,
+		"job_id": active.ID,
+		"kind":   active.Kind,
+	})
+}
+
+// runSyncJob runs a synchronous playlist-modifying request through the
+// scheduler, so it waits for a free worker and never overlaps another job of
+// the same user. run writes the response; the handler blocks until it has.
+func runSyncJob(c *gin.Context, userID string, run func(ctx context.Context)) {
+	reservation, active := jobScheduler.Reserve(userID, jobs.ActiveJob{
+		ID:   uuid.New().String(),
+		Kind: jobs.KindSync,
+	})
+	if reservation == nil {
+		respondJobBusy(c, active)
+		return
+	}
+
+	<-reservation.Start(c.Request.Context(), run)
+}
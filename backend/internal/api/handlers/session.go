@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spotify-genre-organizer/backend/internal/auth"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+const (
+	// sessionCookie holds the signed ID of the browser's session
+	sessionCookie = "session"
+
+	// sessionContextKey is where RequireSession leaves the session
+	sessionContextKey = "session"
+)
+
+var (
+	tokenStore auth.TokenStore
+	userTokens *auth.Tokens
+
+	sessionStore auth.SessionStore
+	sessionKey   []byte
+	sessions     *auth.Sessions
+)
+
+// SetTokenStore sets where users' own Spotify tokens are kept for auto-sync.
+// Without one they live in memory only. It is called once during setup,
+// before any request is served.
+func SetTokenStore(store auth.TokenStore) {
+	tokenStore = store
+	userTokens = auth.NewTokens(getSpotifyConfig(), store)
+}
+
+func getTokens() *auth.Tokens {
+	return userTokens
+}
+
+// SetSessions sets where sessions are kept and the secret their cookies are
+// signed with. Without a store sessions live in memory, and without a secret
+// one is made up at startup. It is called once during setup, before any
+// request is served, so every request checks cookies against the same key.
+func SetSessions(store auth.SessionStore, key []byte) {
+	sessionStore = store
+	sessionKey = key
+	if store == nil {
+		store = auth.NewMemorySessionStore()
+	}
+	sessions = auth.NewSessions(getSpotifyConfig(), store, key)
+}
+
+func getSessions() *auth.Sessions {
+	return sessions
+}
+
+// RequireSession rejects requests without a valid session cookie. Handlers
+// behind it find the user with sessionUserID and act for them on Spotify with
+// sessionClient.
+func RequireSession(c *gin.Context) {
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	session, err := getSessions().Get(cookie)
+	if err != nil {
+		log.Printf("Failed to load session: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load session"})
+		return
+	}
+	if session == nil {
+		setCookie(c, sessionCookie, "", -1, "/", isProduction(), true)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	c.Set(sessionContextKey, session)
+	c.Next()
+}
+
+func currentSession(c *gin.Context) *models.Session {
+	return c.MustGet(sessionContextKey).(*models.Session)
+}
+
+// sessionUserID returns the Spotify ID of the signed-in user
+func sessionUserID(c *gin.Context) string {
+	return currentSession(c).UserID
+}
+
+// sessionClient returns a Spotify client acting for the signed-in user. Its
+// token is refreshed before it expires, also in jobs that outlive the
+// request, and saved to the session.
+func sessionClient(c *gin.Context) *spotify.Client {
+	return getSpotifyConfig().NewClientWithTokens(getSessions().Source(currentSession(c)))
+}
+
+// clearLegacyCookies removes the cookies that held the user ID and access
+// token before sessions were kept server-side
+func clearLegacyCookies(c *gin.Context) {
+	secure := isProduction()
+	setCookie(c, "user_id", "", -1, "/", secure, true)
+	setCookie(c, "access_token", "", -1, "/", secure, true)
+}
@@ -6,16 +6,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
 )
 
 func GetSettings(c *gin.Context) {
-	// userID, _ := c.Cookie("user_id") // Should use authenticated user from middleware if available
-	// For now, getting from cookie is fine as per existing pattern
-	userID, err := c.Cookie("user_id")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	userID := sessionUserID(c)
 
 	settings, err := database.GetUserSettings(userID)
 	if err != nil {
@@ -29,14 +24,14 @@ func GetSettings(c *gin.Context) {
 type UpdateSettingsRequest struct {
 	NameTemplate        string `json:"name_template"`
 	DescriptionTemplate string `json:"description_template"`
+
+	// UnlikedTracks and AutoSync are left unchanged when empty
+	UnlikedTracks string `json:"unliked_tracks"`
+	AutoSync      string `json:"auto_sync"`
 }
 
 func UpdateSettings(c *gin.Context) {
-	userID, err := c.Cookie("user_id")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	userID := sessionUserID(c)
 
 	var req UpdateSettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -60,6 +55,16 @@ func UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	if req.UnlikedTracks != "" && !models.IsValidUnlikedTracks(req.UnlikedTracks) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unliked tracks must be keep, remove or archive"})
+		return
+	}
+
+	if req.AutoSync != "" && !models.IsValidAutoSync(req.AutoSync) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Auto-sync must be off, hourly, daily or weekly"})
+		return
+	}
+
 	settings, err := database.GetUserSettings(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch settings"})
@@ -68,6 +73,12 @@ func UpdateSettings(c *gin.Context) {
 
 	settings.NameTemplate = req.NameTemplate
 	settings.DescriptionTemplate = req.DescriptionTemplate
+	if req.UnlikedTracks != "" {
+		settings.UnlikedTracks = req.UnlikedTracks
+	}
+	if req.AutoSync != "" {
+		settings.AutoSync = req.AutoSync
+	}
 
 	if err := database.SaveUserSettings(settings); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save settings"})
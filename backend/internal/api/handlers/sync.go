@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spotify-genre-organizer/backend/internal/database"
-	"github.com/spotify-genre-organizer/backend/internal/genres"
+	"github.com/spotify-genre-organizer/backend/internal/library"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/playlistsync"
 	"github.com/spotify-genre-organizer/backend/internal/spotify"
 )
 
@@ -23,17 +28,9 @@ type SyncStatusResponse struct {
 }
 
 func GetSyncStatus(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
-
-	userID, err := c.Cookie("user_id")
-	if err != nil || userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	ctx := c.Request.Context()
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
 	// Get oldest sync timestamp
 	oldestSync, err := database.GetOldestSyncTimestamp(userID)
@@ -52,8 +49,8 @@ func GetSyncStatus(c *gin.Context) {
 		return
 	}
 
-	// Fetch all liked songs
-	songs, err := spotify.FetchAllLikedSongs(accessToken, nil)
+	// Catch up on songs liked since the last look at the library
+	snapshot, _, err := library.Refresh(ctx, client, libraryStore, userID, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch songs"})
 		return
@@ -62,12 +59,7 @@ func GetSyncStatus(c *gin.Context) {
 	// Filter to songs added after oldest sync
 	// Subtract 1 minute buffer to account for timezone/precision edge cases
 	syncThreshold := oldestSync.Add(-1 * time.Minute)
-	var newSongs []spotify.Song
-	for _, song := range songs {
-		if song.AddedAt.After(syncThreshold) {
-			newSongs = append(newSongs, song)
-		}
-	}
+	newSongs := snapshot.AddedAfter(syncThreshold)
 
 	if len(newSongs) == 0 {
 		c.JSON(http.StatusOK, SyncStatusResponse{
@@ -78,14 +70,6 @@ func GetSyncStatus(c *gin.Context) {
 		return
 	}
 
-	// Enrich new songs with genres
-	artistGenres, err := spotify.FetchAllArtistGenres(accessToken, newSongs, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch genres"})
-		return
-	}
-	spotify.EnrichSongsWithGenres(newSongs, artistGenres)
-
 	// Get user's playlist overrides to know which playlists exist
 	overrides, err := database.GetPlaylistOverrides(userID)
 	if err != nil {
@@ -96,14 +80,13 @@ func GetSyncStatus(c *gin.Context) {
 	// Count new songs per genre/playlist
 	genreCounts := make(map[string]int)
 	for _, song := range newSongs {
-		genre := genres.ScoreGenres(song.Genres)
-		genreCounts[genre]++
+		genreCounts[snapshot.ParentGenre(song.ID)]++
 	}
 
 	// Build playlist status list
 	var playlistStatuses []PlaylistSyncStatus
 	for playlistID, override := range overrides {
-		if override.Genre != "" {
+		if override.Genre != "" && override.Policy() != models.SyncPolicyFrozen {
 			count := genreCounts[override.Genre]
 			if count > 0 {
 				playlistStatuses = append(playlistStatuses, PlaylistSyncStatus{
@@ -125,93 +108,227 @@ func GetSyncStatus(c *gin.Context) {
 type SyncAllResponse struct {
 	PlaylistsUpdated int      `json:"playlists_updated"`
 	TotalSongs       int      `json:"total_songs"`
+	TracksAdded      int      `json:"tracks_added"`
+	TracksRemoved    int      `json:"tracks_removed"`
 	FailedPlaylists  []string `json:"failed_playlists,omitempty"`
+
+	// TracksUnliked counts tracks found in playlists that are no longer
+	// liked; TracksArchived how many of them were added to the Unliked
+	// playlist
+	TracksUnliked  int `json:"tracks_unliked"`
+	TracksArchived int `json:"tracks_archived"`
+
+	// PlaylistsFrozen counts playlists left alone by their sync policy
+	PlaylistsFrozen int `json:"playlists_frozen"`
+
+	// Changes lists the playlists whose tracks changed and how
+	Changes []*playlistsync.Result `json:"changes"`
 }
 
 func SyncAllPlaylists(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	userID := sessionUserID(c)
+	client := sessionClient(c)
 
-	userID, err := c.Cookie("user_id")
-	if err != nil || userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
-		return
-	}
+	runSyncJob(c, userID, func(ctx context.Context) {
+		syncAllPlaylists(ctx, c, client, userID)
+	})
+}
 
-	// Fetch all liked songs
-	songs, err := spotify.FetchAllLikedSongs(accessToken, nil)
+func syncAllPlaylists(ctx context.Context, c *gin.Context, client *spotify.Client, userID string) {
+	response, err := syncAll(ctx, client, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch songs"})
+		var failed *syncFailure
+		if errors.As(err, &failed) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": failed.message})
+			return
+		}
+		// The client went away part way through
+		log.Printf("sync-all for %s aborted: %v", userID, err)
 		return
 	}
 
-	// Enrich with genres
-	artistGenres, err := spotify.FetchAllArtistGenres(accessToken, songs, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch genres"})
-		return
-	}
-	spotify.EnrichSongsWithGenres(songs, artistGenres)
+	c.JSON(http.StatusOK, response)
+}
 
-	// Group songs by genre
-	songsByGenre := make(map[string][]spotify.Song)
-	for _, song := range songs {
-		genre := genres.ScoreGenres(song.Genres)
-		songsByGenre[genre] = append(songsByGenre[genre], song)
+// syncFailure is a sync that failed before touching any playlist; message is
+// shown to the client
+type syncFailure struct {
+	message string
+	err     error
+}
+
+func (e *syncFailure) Error() string { return e.message + ": " + e.err.Error() }
+func (e *syncFailure) Unwrap() error { return e.err }
+
+// syncAll brings every playlist the user has synced with a genre up to date
+// with their library, as sync-all does. It is shared with auto-sync.
+// Playlists that fail are listed in the response; it only returns an error
+// if it couldn't start, or ctx was cancelled part way.
+func syncAll(ctx context.Context, client *spotify.Client, userID string) (*SyncAllResponse, error) {
+	// Bring the stored library up to date, already grouped by genre
+	snapshot, _, err := library.Refresh(ctx, client, libraryStore, userID, nil)
+	if err != nil {
+		return nil, &syncFailure{"failed to fetch songs", err}
 	}
+	songsByGenre := snapshot.ByParentGenre()
 
 	// Get user's playlist overrides
 	overrides, err := database.GetPlaylistOverrides(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get playlists"})
-		return
+		return nil, &syncFailure{"failed to get playlists", err}
+	}
+
+	settings, err := database.GetUserSettings(userID)
+	if err != nil {
+		return nil, &syncFailure{"failed to fetch settings", err}
 	}
+	opts := unlikedOptions(snapshot, settings)
 
-	playlistsUpdated := 0
-	totalSongs := 0
-	var failedPlaylists []string
+	response := &SyncAllResponse{Changes: []*playlistsync.Result{}}
 	now := time.Now()
+	var unliked []string
 
 	for playlistID, override := range overrides {
-		if override.Genre == "" {
-			continue
+		// Stop touching playlists once the caller has gone away
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-
-		genreSongs := songsByGenre[override.Genre]
-		if len(genreSongs) == 0 {
+		if override.Genre == "" || isUnlikedArchive(settings, playlistID) {
 			continue
 		}
-
-		// Clear and repopulate playlist
-		if err := spotify.ClearPlaylist(accessToken, playlistID); err != nil {
-			failedPlaylists = append(failedPlaylists, override.Genre)
+		if override.Policy() == models.SyncPolicyFrozen {
+			response.PlaylistsFrozen++
 			continue
 		}
 
+		// A genre none of the liked songs are in any more still gets synced,
+		// so the tracks that were unliked leave the playlist
+		genreSongs := songsByGenre[override.Genre]
+
+		// Apply only what changed
 		trackIDs := make([]string, len(genreSongs))
 		for i, s := range genreSongs {
 			trackIDs[i] = s.ID
 		}
 
-		if err := spotify.AddTracksToPlaylist(accessToken, playlistID, trackIDs); err != nil {
-			failedPlaylists = append(failedPlaylists, override.Genre)
+		result, err := playlistsync.Sync(ctx, client, playlistID, trackIDs, policyOptions(opts, override))
+		if err != nil {
+			log.Printf("sync-all for %s: playlist %s failed: %v", userID, playlistID, err)
+			response.FailedPlaylists = append(response.FailedPlaylists, override.Genre)
 			continue
 		}
+		// The unliked tracks have left the playlist, so they are archived
+		// even if what follows fails
+		unliked = append(unliked, result.Unliked...)
 
-		// Update last_synced_at
-		override.LastSyncedAt = &now
-		database.SavePlaylistOverride(override)
+		if err := saveSyncState(override, result, now); err != nil {
+			log.Printf("sync-all for %s: failed to save playlist %s: %v", userID, playlistID, err)
+			response.FailedPlaylists = append(response.FailedPlaylists, override.Genre)
+			continue
+		}
 
-		playlistsUpdated++
-		totalSongs += len(genreSongs)
+		response.PlaylistsUpdated++
+		response.TotalSongs += len(genreSongs)
+		response.TracksUnliked += len(result.Unliked)
+		if result.Changed() {
+			response.TracksAdded += len(result.Added)
+			response.TracksRemoved += len(result.Removed)
+			response.Changes = append(response.Changes, result)
+		}
 	}
 
-	c.JSON(http.StatusOK, SyncAllResponse{
-		PlaylistsUpdated: playlistsUpdated,
-		TotalSongs:       totalSongs,
-		FailedPlaylists:  failedPlaylists,
-	})
+	if settings.UnlikedTracks == models.UnlikedArchive && len(unliked) > 0 {
+		archived, err := archiveUnliked(ctx, client, settings, unliked)
+		if err != nil {
+			log.Printf("sync-all for %s: failed to archive %d unliked tracks: %v", userID, len(unliked), err)
+			response.FailedPlaylists = append(response.FailedPlaylists, unlikedPlaylistName)
+		} else {
+			response.TracksArchived = len(archived.Added)
+		}
+	}
+
+	return response, nil
+}
+
+// unlikedPlaylistName names the playlist tracks that are no longer liked are
+// archived to. It deliberately doesn't follow the naming template, so the
+// archive isn't mistaken for a genre playlist.
+const unlikedPlaylistName = "Unliked"
+
+// unlikedOptions tells sync which playlist tracks are still liked and what to
+// do with the others
+func unlikedOptions(snapshot *library.Snapshot, settings *models.UserSettings) playlistsync.Options {
+	liked := make(map[string]bool, len(snapshot.Songs))
+	for _, song := range snapshot.Songs {
+		liked[song.ID] = true
+	}
+
+	return playlistsync.Options{
+		Liked:   func(trackID string) bool { return liked[trackID] },
+		Unliked: settings.UnlikedTracks,
+	}
+}
+
+// policyOptions applies the playlist's sync policy and the tracks sync put in
+// it to opts. Callers skip frozen playlists before getting here; should one
+// slip through, Sync refuses it.
+func policyOptions(opts playlistsync.Options, override *models.PlaylistOverride) playlistsync.Options {
+	opts.AppendOnly = override.Policy() == models.SyncPolicyAppendOnly
+	opts.Frozen = override.Policy() == models.SyncPolicyFrozen
+	opts.Managed = override.ManagedTracks
+	return opts
+}
+
+// saveSyncState records when the playlist was synced and which of its tracks
+// sync put there. Without ManagedTracks the tracks just added would pass for
+// the user's own and never be removed, so a sync whose state couldn't be
+// saved has failed.
+func saveSyncState(override *models.PlaylistOverride, result *playlistsync.Result, syncedAt time.Time) error {
+	override.LastSyncedAt = &syncedAt
+	override.ManagedTracks = result.Managed
+	return database.SavePlaylistOverride(override)
+}
+
+// isUnlikedArchive reports whether playlistID is the user's Unliked playlist,
+// which sync never treats as a genre playlist
+func isUnlikedArchive(settings *models.UserSettings, playlistID string) bool {
+	return settings.UnlikedPlaylistID != nil && *settings.UnlikedPlaylistID == playlistID
+}
+
+// archiveUnliked adds tracks to the user's Unliked playlist, creating it if
+// it doesn't exist or was deleted
+func archiveUnliked(ctx context.Context, client *spotify.Client, settings *models.UserSettings, trackIDs []string) (*playlistsync.Result, error) {
+	playlistID := ""
+	if settings.UnlikedPlaylistID != nil {
+		// Only a playlist that is really gone is replaced; if Spotify can't
+		// tell, creating another would leave the user with duplicates
+		playlist, err := client.GetPlaylist(ctx, *settings.UnlikedPlaylistID)
+		if err != nil {
+			return nil, err
+		}
+		if playlist != nil && playlist.OwnerID == settings.UserID {
+			following, err := client.IsFollowingPlaylist(ctx, playlist.ID)
+			if err != nil {
+				return nil, err
+			}
+			if following {
+				playlistID = playlist.ID
+			}
+		}
+	}
+
+	if playlistID == "" {
+		playlist, err := client.CreatePlaylist(ctx, settings.UserID, unlikedPlaylistName, "Songs you unliked, moved out of your genre playlists")
+		if err != nil {
+			return nil, err
+		}
+		playlistID = playlist.ID
+
+		settings.UnlikedPlaylistID = &playlistID
+		if err := database.SaveUserSettings(settings); err != nil {
+			log.Printf("failed to remember Unliked playlist %s for %s: %v", playlistID, settings.UserID, err)
+		}
+	}
+
+	return playlistsync.Archive(ctx, client, playlistID, trackIDs)
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/spotify-genre-organizer/backend/internal/autosync"
+	"github.com/spotify-genre-organizer/backend/internal/jobs"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+func TestArchiveUnlikedKeepsOneUnlikedPlaylist(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	client := srv.Config().NewClient(srv.AddUser("alice"))
+	ctx := context.Background()
+
+	settings := models.DefaultSettings("alice")
+	settings.UnlikedTracks = models.UnlikedArchive
+
+	if _, err := archiveUnliked(ctx, client, settings, []string{"a", "b"}); err != nil {
+		t.Fatalf("first archive: %v", err)
+	}
+	if settings.UnlikedPlaylistID == nil {
+		t.Fatalf("expected the Unliked playlist to be remembered")
+	}
+	archiveID := *settings.UnlikedPlaylistID
+
+	result, err := archiveUnliked(ctx, client, settings, []string{"b", "c"})
+	if err != nil {
+		t.Fatalf("second archive: %v", err)
+	}
+	if result.PlaylistID != archiveID || !reflect.DeepEqual(result.Added, []string{"c"}) {
+		t.Errorf("expected only c added to %s, got %+v", archiveID, result)
+	}
+
+	p, _ := srv.Playlist(archiveID)
+	if p.Name != unlikedPlaylistName || !reflect.DeepEqual(p.TrackIDs(), []string{"a", "b", "c"}) {
+		t.Errorf("unexpected archive playlist %q with %v", p.Name, p.TrackIDs())
+	}
+
+	// A deleted archive is replaced rather than written to
+	if err := client.UnfollowPlaylist(ctx, archiveID); err != nil {
+		t.Fatalf("unfollow: %v", err)
+	}
+	result, err = archiveUnliked(ctx, client, settings, []string{"d"})
+	if err != nil {
+		t.Fatalf("third archive: %v", err)
+	}
+	if result.PlaylistID == archiveID || *settings.UnlikedPlaylistID != result.PlaylistID {
+		t.Errorf("expected a new Unliked playlist, got %s", result.PlaylistID)
+	}
+}
+
+func TestArchiveUnlikedReplacesDeletedPlaylist(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	client := srv.Config().NewClient(srv.AddUser("alice"))
+	ctx := context.Background()
+
+	// Spotify answers 404 for a playlist that was deleted outright
+	settings := models.DefaultSettings("alice")
+	settings.UnlikedTracks = models.UnlikedArchive
+	deletedID := "deleted-unliked"
+	settings.UnlikedPlaylistID = &deletedID
+
+	result, err := archiveUnliked(ctx, client, settings, []string{"a"})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if result.PlaylistID == deletedID || *settings.UnlikedPlaylistID != result.PlaylistID {
+		t.Errorf("expected a new Unliked playlist, got %s", result.PlaylistID)
+	}
+}
+
+func TestArchiveUnlikedDoesNotDuplicateOnSpotifyErrors(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	config := srv.Config()
+	config.MaxRetries = 1
+	client := config.NewClient(srv.AddUser("alice"))
+	ctx := context.Background()
+
+	settings := models.DefaultSettings("alice")
+	settings.UnlikedTracks = models.UnlikedArchive
+	if _, err := archiveUnliked(ctx, client, settings, []string{"a"}); err != nil {
+		t.Fatalf("first archive: %v", err)
+	}
+	archiveID := *settings.UnlikedPlaylistID
+
+	srv.FailNext(2, http.StatusInternalServerError)
+	if _, err := archiveUnliked(ctx, client, settings, []string{"b"}); err == nil {
+		t.Fatalf("expected the Spotify error to be returned")
+	}
+	if *settings.UnlikedPlaylistID != archiveID || len(srv.Playlists("alice")) != 1 {
+		t.Errorf("expected no second Unliked playlist, got %d playlists", len(srv.Playlists("alice")))
+	}
+}
+
+func TestAutoSyncSkipsUsersWithAJobRunning(t *testing.T) {
+	reservation, _ := jobScheduler.Reserve("alice", jobs.ActiveJob{ID: "manual", Kind: jobs.KindSync})
+	if reservation == nil {
+		t.Fatal("expected to reserve alice's job slot")
+	}
+	defer reservation.Release()
+
+	err := AutoSync(context.Background(), &models.AutoSyncRun{ID: "run", UserID: "alice"})
+	if !errors.Is(err, autosync.ErrSkipped) {
+		t.Errorf("expected the run to be skipped, got %v", err)
+	}
+}
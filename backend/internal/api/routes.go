@@ -111,24 +111,36 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			auth.GET("/login", handlers.Login)
 			auth.GET("/callback", handlers.Callback)
-			auth.GET("/me", handlers.Me)
+			auth.GET("/me", handlers.RequireSession, handlers.Me)
 			auth.POST("/logout", handlers.Logout)
 		}
 
-		api.POST("/organize", handlers.StartOrganize)
-		api.GET("/organize/:id", handlers.GetOrganizeStatus)
-
-		api.GET("/library/count", handlers.GetLibraryCount)
-
-		api.GET("/settings", handlers.GetSettings)
-		api.PUT("/settings", handlers.UpdateSettings)
-
-		api.GET("/playlists", handlers.ListPlaylists)
-		api.PATCH("/playlists/:id", handlers.UpdatePlaylist)
-		api.DELETE("/playlists/:id", handlers.DeletePlaylist)
-		api.POST("/playlists/:id/refresh", handlers.RefreshPlaylist)
-
-		api.GET("/library/sync-status", handlers.GetSyncStatus)
-		api.POST("/playlists/sync-all", handlers.SyncAllPlaylists)
+		// Everything else needs a signed-in session
+		signedIn := api.Group("", handlers.RequireSession)
+
+		signedIn.GET("/organize", handlers.ListOrganizeJobs)
+		signedIn.POST("/organize", handlers.StartOrganize)
+		signedIn.GET("/organize/:id", handlers.GetOrganizeStatus)
+		signedIn.GET("/organize/:id/events", handlers.GetOrganizeEvents)
+		signedIn.DELETE("/organize/:id", handlers.CancelOrganize)
+		signedIn.GET("/organize/plans/:id", handlers.GetOrganizePlan)
+		signedIn.PATCH("/organize/plans/:id", handlers.EditOrganizePlan)
+		signedIn.POST("/organize/plans/:id/apply", handlers.ApplyOrganizePlan)
+
+		signedIn.GET("/library/count", handlers.GetLibraryCount)
+		signedIn.GET("/library/genres", handlers.GetLibraryGenres)
+
+		signedIn.GET("/settings", handlers.GetSettings)
+		signedIn.PUT("/settings", handlers.UpdateSettings)
+		signedIn.GET("/auto-sync/runs", handlers.GetAutoSyncRuns)
+
+		signedIn.GET("/playlists", handlers.ListPlaylists)
+		signedIn.GET("/playlists/:id", handlers.GetPlaylist)
+		signedIn.PATCH("/playlists/:id", handlers.UpdatePlaylist)
+		signedIn.DELETE("/playlists/:id", handlers.DeletePlaylist)
+		signedIn.POST("/playlists/:id/refresh", handlers.RefreshPlaylist)
+
+		signedIn.GET("/library/sync-status", handlers.GetSyncStatus)
+		signedIn.POST("/playlists/sync-all", handlers.SyncAllPlaylists)
 	}
 }
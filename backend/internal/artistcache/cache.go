@@ -0,0 +1,195 @@
+// Package artistcache caches the genres of Spotify artists for every user,
+// in an in-memory LRU in front of the artist_genres table.
+package artistcache
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// Defaults used when the cache is created with zero values
+const (
+	DefaultCapacity = 50000
+	DefaultTTL      = 7 * 24 * time.Hour
+)
+
+// Store is where cached artist genres outlive the process. Get leaves out
+// artists it doesn't have.
+type Store interface {
+	Get(artistIDs []string) ([]models.ArtistGenres, error)
+	Save(entries []models.ArtistGenres) error
+}
+
+// Cache implements spotify.ArtistGenreCache. Entries older than the TTL are
+// treated as missing, so the artist is fetched from Spotify again.
+type Cache struct {
+	store    Store
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+
+	memoryHits   atomic.Int64
+	databaseHits atomic.Int64
+	misses       atomic.Int64
+	evictions    atomic.Int64
+}
+
+type entry struct {
+	artistID  string
+	genres    []string
+	fetchedAt time.Time
+}
+
+// Stats counts how artist lookups were answered, per artist
+type Stats struct {
+	MemoryHits   int64 `json:"memory_hits"`
+	DatabaseHits int64 `json:"database_hits"`
+	Misses       int64 `json:"misses"`
+	Evictions    int64 `json:"evictions"`
+	Entries      int   `json:"entries"`
+}
+
+// New returns a cache holding up to capacity artists in memory, backed by
+// store. A nil store keeps the cache in memory only.
+func New(store Store, capacity int, ttl time.Duration) *Cache {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Cache{
+		store:    store,
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the fresh cached genres of the given artists, by artist ID.
+// Artists missing from memory are looked up in the store in one go.
+func (c *Cache) Get(artistIDs []string) map[string][]string {
+	found := make(map[string][]string, len(artistIDs))
+	var missing []string
+
+	c.mu.Lock()
+	for _, id := range artistIDs {
+		if genres, ok := c.getLocked(id); ok {
+			found[id] = genres
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	c.mu.Unlock()
+	c.memoryHits.Add(int64(len(found)))
+
+	if len(missing) > 0 && c.store != nil {
+		stored, err := c.store.Get(missing)
+		if err != nil {
+			log.Printf("artist cache: failed to load %d artists: %v", len(missing), err)
+		}
+
+		c.mu.Lock()
+		for _, e := range stored {
+			if c.expired(e.FetchedAt) {
+				continue
+			}
+			if _, ok := found[e.ArtistID]; ok {
+				continue
+			}
+			found[e.ArtistID] = e.Genres
+			c.addLocked(e.ArtistID, e.Genres, e.FetchedAt)
+			c.databaseHits.Add(1)
+		}
+		c.mu.Unlock()
+	}
+
+	c.misses.Add(int64(len(artistIDs) - len(found)))
+	return found
+}
+
+// Set caches freshly fetched artist genres in memory and in the store
+func (c *Cache) Set(artistGenres map[string][]string) {
+	now := c.now()
+	entries := make([]models.ArtistGenres, 0, len(artistGenres))
+
+	c.mu.Lock()
+	for id, genres := range artistGenres {
+		if genres == nil {
+			genres = []string{}
+		}
+		c.addLocked(id, genres, now)
+		entries = append(entries, models.ArtistGenres{ArtistID: id, Genres: genres, FetchedAt: now})
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		if err := c.store.Save(entries); err != nil {
+			log.Printf("artist cache: failed to save %d artists: %v", len(entries), err)
+		}
+	}
+}
+
+// Stats returns the lookup counters accumulated so far
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		MemoryHits:   c.memoryHits.Load(),
+		DatabaseHits: c.databaseHits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		Entries:      size,
+	}
+}
+
+func (c *Cache) getLocked(id string) ([]string, bool) {
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if c.expired(e.fetchedAt) {
+		c.order.Remove(el)
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.genres, true
+}
+
+func (c *Cache) addLocked(id string, genres []string, fetchedAt time.Time) {
+	if el, ok := c.entries[id]; ok {
+		el.Value = &entry{artistID: id, genres: genres, fetchedAt: fetchedAt}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[id] = c.order.PushFront(&entry{artistID: id, genres: genres, fetchedAt: fetchedAt})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).artistID)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *Cache) expired(fetchedAt time.Time) bool {
+	return c.now().Sub(fetchedAt) >= c.ttl
+}
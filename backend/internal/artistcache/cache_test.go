@@ -0,0 +1,156 @@
+package artistcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+// memoryStore stands in for the artist_genres table
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]models.ArtistGenres
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]models.ArtistGenres)}
+}
+
+func (s *memoryStore) Get(artistIDs []string) ([]models.ArtistGenres, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []models.ArtistGenres
+	for _, id := range artistIDs {
+		if e, ok := s.entries[id]; ok {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func (s *memoryStore) Save(entries []models.ArtistGenres) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range entries {
+		s.entries[e.ArtistID] = e
+	}
+	return nil
+}
+
+func fetchGenres(t *testing.T, srv *spotifytest.Server, cache *Cache, token string) map[string][]string {
+	t.Helper()
+
+	config := srv.Config()
+	config.ArtistGenres = cache
+	songs := []spotify.Song{
+		{ID: "t1", Artists: []spotify.Artist{{ID: "rock-artist"}, {ID: "quiet-artist"}}},
+		{ID: "t2", Artists: []spotify.Artist{{ID: "jazz-artist"}}},
+	}
+
+	genres, err := config.NewClient(token).FetchAllArtistGenres(context.Background(), songs, nil)
+	if err != nil {
+		t.Fatalf("FetchAllArtistGenres: %v", err)
+	}
+	return genres
+}
+
+func artistRequests(srv *spotifytest.Server) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if req == "GET /v1/artists" {
+			n++
+		}
+	}
+	return n
+}
+
+func newFakeSpotify(t *testing.T) (*spotifytest.Server, string) {
+	t.Helper()
+
+	srv := spotifytest.NewServer()
+	t.Cleanup(srv.Close)
+
+	token := srv.AddUser("alice")
+	srv.SetArtistGenres("rock-artist", "indie rock")
+	srv.SetArtistGenres("jazz-artist", "jazz fusion")
+	srv.SetArtistGenres("quiet-artist")
+	return srv, token
+}
+
+func TestCacheAnswersRepeatLookups(t *testing.T) {
+	srv, token := newFakeSpotify(t)
+	store := newMemoryStore()
+	cache := New(store, 0, time.Hour)
+
+	fetchGenres(t, srv, cache, token)
+	if n := artistRequests(srv); n != 1 {
+		t.Fatalf("expected 1 request to fill the cache, got %d", n)
+	}
+
+	genres := fetchGenres(t, srv, cache, token)
+	if n := artistRequests(srv); n != 1 {
+		t.Errorf("expected cached artists not to be fetched again, got %d requests", n)
+	}
+	if len(genres["rock-artist"]) != 1 || genres["quiet-artist"] == nil {
+		t.Errorf("unexpected cached genres %v", genres)
+	}
+
+	stats := cache.Stats()
+	if stats.Misses != 3 || stats.MemoryHits != 3 || stats.Entries != 3 {
+		t.Errorf("expected 3 misses then 3 memory hits, got %+v", stats)
+	}
+
+	// A new process starts with an empty memory but finds the stored copies
+	restarted := New(store, 0, time.Hour)
+	fetchGenres(t, srv, restarted, token)
+	if n := artistRequests(srv); n != 1 {
+		t.Errorf("expected stored artists not to be fetched again, got %d requests", n)
+	}
+	if stats := restarted.Stats(); stats.DatabaseHits != 3 || stats.Misses != 0 {
+		t.Errorf("expected 3 database hits, got %+v", stats)
+	}
+}
+
+func TestCacheRefetchesExpiredArtists(t *testing.T) {
+	srv, token := newFakeSpotify(t)
+	store := newMemoryStore()
+	cache := New(store, 0, time.Hour)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	fetchGenres(t, srv, cache, token)
+
+	now = now.Add(2 * time.Hour)
+	fetchGenres(t, srv, cache, token)
+
+	if n := artistRequests(srv); n != 2 {
+		t.Errorf("expected expired artists to be fetched again, got %d requests", n)
+	}
+	if stats := cache.Stats(); stats.Misses != 6 || stats.MemoryHits != 0 || stats.DatabaseHits != 0 {
+		t.Errorf("expected every lookup to miss, got %+v", stats)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New(nil, 2, time.Hour)
+
+	cache.Set(map[string][]string{"a": {"rock"}})
+	cache.Set(map[string][]string{"b": {"jazz"}})
+	cache.Get([]string{"a"})
+	cache.Set(map[string][]string{"c": {"pop"}})
+
+	found := cache.Get([]string{"a", "b", "c"})
+	if _, ok := found["b"]; ok || len(found) != 2 {
+		t.Errorf("expected b to be evicted, got %v", found)
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("expected 1 eviction and 2 entries, got %+v", stats)
+	}
+}
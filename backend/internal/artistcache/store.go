@@ -0,0 +1,22 @@
+package artistcache
+
+import (
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// DatabaseStore keeps cached artist genres in the artist_genres table, shared
+// by every replica
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Get(artistIDs []string) ([]models.ArtistGenres, error) {
+	return database.GetArtistGenres(artistIDs)
+}
+
+func (s *DatabaseStore) Save(entries []models.ArtistGenres) error {
+	return database.UpsertArtistGenres(entries)
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TokenKeyEnv names the environment variable holding the key tokens are
+// encrypted with at rest: 32 random bytes, base64-encoded
+const TokenKeyEnv = "TOKEN_ENCRYPTION_KEY"
+
+// sealedPrefix marks values written by Cipher.Seal and their format version
+const sealedPrefix = "v1:"
+
+// ErrNoKey is returned by CipherFromEnv when no key is configured
+var ErrNoKey = errors.New(TokenKeyEnv + " is not set")
+
+// errNotSealed means a value wasn't written by Cipher.Seal, e.g. a token
+// stored before encryption was added
+var errNotSealed = errors.New("value is not encrypted")
+
+// Cipher encrypts tokens with AES-256-GCM. Each value is bound to the user it
+// belongs to, so a sealed token copied to another user's row won't open.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a cipher for a 32-byte key
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("token encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// CipherFromEnv returns a cipher for the key in TOKEN_ENCRYPTION_KEY
+func CipherFromEnv() (*Cipher, error) {
+	value := os.Getenv(TokenKeyEnv)
+	if value == "" {
+		return nil, ErrNoKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", TokenKeyEnv, err)
+	}
+	return NewCipher(key)
+}
+
+// Seal encrypts plaintext for userID into a printable value
+func (c *Cipher) Seal(plaintext, userID string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(userID))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value Seal returned for userID. It fails for values sealed
+// with another key or for another user, and for values stored unencrypted.
+func (c *Cipher) Open(value, userID string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", errNotSealed
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errNotSealed
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+
+	sealed, err := c.Seal("access-token", "alice")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if strings.Contains(sealed, "access-token") || !strings.HasPrefix(sealed, sealedPrefix) {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+	if again, _ := c.Seal("access-token", "alice"); again == sealed {
+		t.Errorf("expected a fresh nonce for every value")
+	}
+
+	if opened, err := c.Open(sealed, "alice"); err != nil || opened != "access-token" {
+		t.Errorf("expected the token back, got %q, %v", opened, err)
+	}
+
+	other, _ := NewCipher(bytes.Repeat([]byte{2}, 32))
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		value  string
+		userID string
+	}{
+		{"another user", c, sealed, "bob"},
+		{"another key", other, sealed, "alice"},
+		{"unencrypted", c, "access-token", "alice"},
+		{"truncated", c, sealedPrefix + "AAAA", "alice"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cipher.Open(tt.value, tt.userID); err == nil {
+			t.Errorf("%s: expected Open to fail", tt.name)
+		}
+	}
+
+	if _, err := NewCipher([]byte("too short")); err == nil {
+		t.Errorf("expected a short key to be rejected")
+	}
+}
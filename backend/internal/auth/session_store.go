@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// MemorySessionStore keeps sessions in memory, for running without a
+// database; they are lost on restart and not shared between instances
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]models.Session)}
+}
+
+func (m *MemorySessionStore) Create(session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *MemorySessionStore) Get(id string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *MemorySessionStore) SaveToken(id string, token Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	session.AccessToken = token.AccessToken
+	session.RefreshToken = token.RefreshToken
+	session.TokenExpiresAt = token.ExpiresAt
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteExpired(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for id, session := range m.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DatabaseSessionStore keeps sessions in the Postgres sessions table, with
+// their tokens encrypted with cipher
+type DatabaseSessionStore struct {
+	cipher *Cipher
+}
+
+func NewDatabaseSessionStore(cipher *Cipher) *DatabaseSessionStore {
+	return &DatabaseSessionStore{cipher: cipher}
+}
+
+func (s *DatabaseSessionStore) Create(session *models.Session) error {
+	sealed := *session
+	var err error
+	if sealed.AccessToken, err = s.cipher.Seal(session.AccessToken, session.ID); err != nil {
+		return err
+	}
+	if sealed.RefreshToken, err = s.cipher.Seal(session.RefreshToken, session.ID); err != nil {
+		return err
+	}
+
+	return database.CreateSession(&sealed)
+}
+
+func (s *DatabaseSessionStore) Get(id string) (*models.Session, error) {
+	session, err := database.GetSession(id)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	// A session whose tokens can't be read, e.g. after the key changed, is
+	// treated as over so the user signs in again
+	if session.AccessToken, err = s.cipher.Open(session.AccessToken, id); err != nil {
+		log.Printf("auth: ignoring session with unreadable tokens: %v", err)
+		return nil, nil
+	}
+	if session.RefreshToken, err = s.cipher.Open(session.RefreshToken, id); err != nil {
+		log.Printf("auth: ignoring session with unreadable tokens: %v", err)
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+func (s *DatabaseSessionStore) SaveToken(id string, token Token) error {
+	accessToken, err := s.cipher.Seal(token.AccessToken, id)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := s.cipher.Seal(token.RefreshToken, id)
+	if err != nil {
+		return err
+	}
+
+	return database.UpdateSessionTokens(id, accessToken, refreshToken, token.ExpiresAt)
+}
+
+func (s *DatabaseSessionStore) Delete(id string) error {
+	return database.DeleteSession(id)
+}
+
+func (s *DatabaseSessionStore) DeleteExpired(now time.Time) (int64, error) {
+	return database.DeleteSessionsExpiredBefore(now)
+}
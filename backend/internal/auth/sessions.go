@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+const (
+	// SessionLifetime is how long a session lasts after signing in
+	SessionLifetime = 30 * 24 * time.Hour
+
+	// SessionKeyEnv names the environment variable holding the secret session
+	// cookies are signed with
+	SessionKeyEnv = "SESSION_SECRET"
+
+	// minSessionKeyLength is the shortest secret accepted, in bytes
+	minSessionKeyLength = 32
+
+	// sessionPruneInterval is how often expired sessions are deleted
+	sessionPruneInterval = time.Hour
+)
+
+// ErrNoSessionKey is returned by SessionKeyFromEnv when no secret is
+// configured
+var ErrNoSessionKey = errors.New(SessionKeyEnv + " is not set")
+
+// SessionStore keeps sessions server-side
+type SessionStore interface {
+	// Create stores a new session
+	Create(session *models.Session) error
+
+	// Get returns the session with id, or nil if there is none
+	Get(id string) (*models.Session, error)
+
+	// SaveToken replaces a session's tokens after they were refreshed
+	SaveToken(id string, token Token) error
+
+	// Delete removes a session
+	Delete(id string) error
+
+	// DeleteExpired removes sessions that expired before now and returns how
+	// many were deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// Sessions signs browsers in and out. A session's ID is random and its
+// cookie carries it signed, so IDs can be neither guessed nor forged, while
+// the user it belongs to and their tokens never leave the server.
+type Sessions struct {
+	store  SessionStore
+	key    []byte
+	tokens *Tokens
+	now    func() time.Time
+}
+
+// NewSessions returns sessions kept in store, with cookies signed with key
+// and tokens refreshed with config. Without a key a random one is used, so
+// sessions end when the process restarts.
+func NewSessions(config *spotify.Config, store SessionStore, key []byte) *Sessions {
+	if len(key) == 0 {
+		key = make([]byte, minSessionKeyLength)
+		rand.Read(key)
+	}
+	return &Sessions{
+		store:  store,
+		key:    key,
+		tokens: NewTokens(config, sessionTokens{store: store}),
+		now:    time.Now,
+	}
+}
+
+// SessionKeyFromEnv reads the secret session cookies are signed with from
+// SESSION_SECRET
+func SessionKeyFromEnv() ([]byte, error) {
+	key := os.Getenv(SessionKeyEnv)
+	if key == "" {
+		return nil, ErrNoSessionKey
+	}
+	if len(key) < minSessionKeyLength {
+		return nil, fmt.Errorf("%s must be at least %d characters", SessionKeyEnv, minSessionKeyLength)
+	}
+	return []byte(key), nil
+}
+
+// Create starts a session for a user who just signed in with token. It
+// returns the session and the value for its cookie.
+func (s *Sessions) Create(userID string, token Token) (*models.Session, string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	session := &models.Session{
+		ID:             base64.RawURLEncoding.EncodeToString(id),
+		UserID:         userID,
+		AccessToken:    token.AccessToken,
+		RefreshToken:   token.RefreshToken,
+		TokenExpiresAt: token.ExpiresAt,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(SessionLifetime),
+	}
+	if err := s.store.Create(session); err != nil {
+		return nil, "", err
+	}
+
+	return session, s.sign(session.ID), nil
+}
+
+// Get returns the session a cookie refers to, or nil if the cookie wasn't
+// signed by us or its session is over
+func (s *Sessions) Get(cookie string) (*models.Session, error) {
+	id, ok := s.verify(cookie)
+	if !ok {
+		return nil, nil
+	}
+
+	session, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || !s.now().Before(session.ExpiresAt) {
+		s.tokens.forget(id)
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Delete ends the session a cookie refers to
+func (s *Sessions) Delete(cookie string) error {
+	id, ok := s.verify(cookie)
+	if !ok {
+		return nil
+	}
+
+	s.tokens.forget(id)
+	return s.store.Delete(id)
+}
+
+// Source returns the token source of a session returned by Get. Refreshed
+// tokens are saved to the session.
+func (s *Sessions) Source(session *models.Session) *Source {
+	return s.tokens.sourceFor(session.ID, sessionToken(session), session.ExpiresAt)
+}
+
+// RunSessionPruning deletes expired sessions from store straight away and
+// then every hour, until ctx is done
+func RunSessionPruning(ctx context.Context, store SessionStore) {
+	ticker := time.NewTicker(sessionPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.DeleteExpired(time.Now())
+		if err != nil {
+			log.Printf("Failed to prune sessions: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Pruned %d expired sessions", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sign returns the cookie value for a session ID: the ID and its HMAC
+func (s *Sessions) sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(s.mac(id))
+}
+
+// verify returns the session ID in a cookie value if its signature holds
+func (s *Sessions) verify(cookie string) (string, bool) {
+	id, signature, ok := strings.Cut(cookie, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *Sessions) mac(id string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(id))
+	return h.Sum(nil)
+}
+
+func sessionToken(session *models.Session) Token {
+	return Token{
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.TokenExpiresAt,
+	}
+}
+
+// sessionTokens lets a session's token source keep refreshed tokens in the
+// session itself
+type sessionTokens struct {
+	store SessionStore
+}
+
+func (t sessionTokens) Load(id string) (*Token, error) {
+	session, err := t.store.Get(id)
+	if err != nil || session == nil {
+		return nil, err
+	}
+	token := sessionToken(session)
+	return &token, nil
+}
+
+func (t sessionTokens) Save(id string, token Token) error {
+	return t.store.SaveToken(id, token)
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+func TestSessionCookies(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+
+	store := NewMemorySessionStore()
+	sessions := NewSessions(srv.Config(), store, []byte(strings.Repeat("k", 32)))
+	token := Token{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)}
+
+	session, cookie, err := sessions.Create("alice", token)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if strings.Contains(cookie, "alice") || strings.Contains(cookie, "access") {
+		t.Errorf("expected an opaque cookie, got %q", cookie)
+	}
+
+	got, err := sessions.Get(cookie)
+	if err != nil || got == nil || got.UserID != "alice" || got.AccessToken != "access" {
+		t.Fatalf("expected alice's session, got %+v, %v", got, err)
+	}
+
+	other, otherCookie, _ := sessions.Create("bob", token)
+	otherID, _, _ := strings.Cut(otherCookie, ".")
+	forged := []string{
+		"",
+		session.ID,
+		session.ID + ".",
+		session.ID + ".bm90IGEgc2lnbmF0dXJl",
+		// Bob's signature doesn't vouch for alice's session
+		session.ID + strings.TrimPrefix(otherCookie, otherID),
+	}
+	for _, value := range forged {
+		if got, _ := sessions.Get(value); got != nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+
+	// Cookies signed with another secret don't work either
+	elsewhere := NewSessions(srv.Config(), store, []byte(strings.Repeat("x", 32)))
+	if got, _ := elsewhere.Get(cookie); got != nil {
+		t.Errorf("expected a cookie signed with another secret to be rejected")
+	}
+
+	// Expired sessions are over and get pruned
+	sessions.now = func() time.Time { return session.ExpiresAt }
+	if got, _ := sessions.Get(cookie); got != nil {
+		t.Errorf("expected an expired session to be rejected")
+	}
+	if deleted, _ := store.DeleteExpired(session.ExpiresAt.Add(time.Second)); deleted != 2 {
+		t.Errorf("expected both sessions to be pruned, got %d", deleted)
+	}
+	if got, _ := store.Get(other.ID); got != nil {
+		t.Errorf("expected bob's session to be gone")
+	}
+}
+
+func TestExpiredSessionSourcesAreDropped(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+
+	sessions := NewSessions(srv.Config(), NewMemorySessionStore(), nil)
+	token := Token{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Sessions used once and never again, as when the browser is closed
+	var expires time.Time
+	for i := 0; i < 3; i++ {
+		session, _, _ := sessions.Create("alice", token)
+		sessions.Source(session)
+		expires = session.ExpiresAt
+	}
+
+	later := expires.Add(time.Minute)
+	sessions.now = func() time.Time { return later }
+	sessions.tokens.now = sessions.now
+	session, _, _ := sessions.Create("bob", token)
+	sessions.Source(session)
+
+	if n := len(sessions.tokens.sources); n != 1 {
+		t.Errorf("expected only bob's session source to be kept, got %d", n)
+	}
+}
+
+func TestSessionTokenIsRefreshedIntoTheSession(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+
+	srv.AddAuthCode("code", "alice")
+	resp, err := srv.Config().ExchangeCode(context.Background(), "code")
+	if err != nil {
+		t.Fatalf("exchange code: %v", err)
+	}
+
+	store := NewMemorySessionStore()
+	sessions := NewSessions(srv.Config(), store, nil)
+	session, cookie, _ := sessions.Create("alice", NewToken(resp, time.Now()))
+
+	// Spotify stops accepting the token mid-job
+	srv.RevokeToken(resp.AccessToken)
+	client := srv.Config().NewClientWithTokens(sessions.Source(session))
+	if _, err := client.GetUserProfile(context.Background()); err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+
+	got, _ := sessions.Get(cookie)
+	if got.AccessToken == resp.AccessToken || got.RefreshToken != resp.RefreshToken {
+		t.Errorf("expected the refreshed token to be saved to the session, got %+v", got)
+	}
+
+	if err := sessions.Delete(cookie); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got, _ := sessions.Get(cookie); got != nil {
+		t.Errorf("expected the session to be over after signing out")
+	}
+}
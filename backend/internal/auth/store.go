@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"log"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+)
+
+// DatabaseTokenStore keeps tokens in the users table, encrypted with cipher.
+// The user's row must exist, see database.UpsertUser.
+type DatabaseTokenStore struct {
+	cipher *Cipher
+}
+
+func NewDatabaseTokenStore(cipher *Cipher) *DatabaseTokenStore {
+	return &DatabaseTokenStore{cipher: cipher}
+}
+
+func (s *DatabaseTokenStore) Load(userID string) (*Token, error) {
+	user, err := database.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.RefreshToken == "" || user.TokenExpiresAt == nil {
+		return nil, nil
+	}
+
+	accessToken, err := s.cipher.Open(user.AccessToken, userID)
+	if err != nil {
+		return s.unreadable(userID, err)
+	}
+	refreshToken, err := s.cipher.Open(user.RefreshToken, userID)
+	if err != nil {
+		return s.unreadable(userID, err)
+	}
+
+	return &Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    *user.TokenExpiresAt,
+	}, nil
+}
+
+// unreadable treats tokens stored unencrypted or under another key as
+// missing, so the user is asked to sign in again
+func (s *DatabaseTokenStore) unreadable(userID string, err error) (*Token, error) {
+	log.Printf("auth: ignoring unreadable tokens for %s: %v", userID, err)
+	return nil, nil
+}
+
+func (s *DatabaseTokenStore) Save(userID string, token Token) error {
+	accessToken, err := s.cipher.Seal(token.AccessToken, userID)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := s.cipher.Seal(token.RefreshToken, userID)
+	if err != nil {
+		return err
+	}
+
+	return database.SaveUserTokens(userID, accessToken, refreshToken, token.ExpiresAt)
+}
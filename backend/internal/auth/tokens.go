@@ -0,0 +1,245 @@
+// Package auth keeps signed-in users' sessions and Spotify tokens, and
+// refreshes access tokens shortly before they expire, so requests and
+// long-running jobs keep working past the hour a token lasts.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// RefreshMargin is how long before it expires an access token is refreshed
+const RefreshMargin = 5 * time.Minute
+
+// ErrNoToken means there are no tokens to act for the user with, e.g. because
+// they haven't signed in since tokens were stored
+var ErrNoToken = errors.New("no stored Spotify tokens, sign in again")
+
+// Token is a user's Spotify access token and the refresh token that renews it
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// NewToken returns the token Spotify issued in resp at now
+func NewToken(resp *spotify.TokenResponse, now time.Time) Token {
+	return Token{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    now.Add(time.Duration(resp.ExpiresIn) * time.Second),
+	}
+}
+
+// TokenStore is where tokens are kept between restarts and shared between
+// instances. Tokens are kept by user ID, or by session ID for a session's.
+type TokenStore interface {
+	// Load returns the tokens stored for id, or nil if there are none
+	Load(id string) (*Token, error)
+
+	// Save stores tokens for id, replacing any it had
+	Save(id string, token Token) error
+}
+
+// Tokens hands out one Source per user or session, so every request and job
+// acting for them shares the same token and refreshes it only once. A
+// session's source is dropped once the session expires, even if it is never
+// used again.
+type Tokens struct {
+	config *spotify.Config
+	store  TokenStore
+	now    func() time.Time
+
+	mu           sync.Mutex
+	sources      map[string]*Source
+	nextEviction time.Time
+}
+
+// NewTokens returns a Tokens that refreshes with config. A nil store keeps
+// tokens in memory only, so they are lost on restart.
+func NewTokens(config *spotify.Config, store TokenStore) *Tokens {
+	return &Tokens{
+		config:  config,
+		store:   store,
+		now:     time.Now,
+		sources: make(map[string]*Source),
+	}
+}
+
+// Save records the tokens a user just signed in with. They are used from now
+// on even if storing them fails.
+func (t *Tokens) Save(userID string, token Token) error {
+	source := &Source{tokens: t, id: userID, token: token}
+
+	t.mu.Lock()
+	t.sources[userID] = source
+	t.mu.Unlock()
+
+	if t.store == nil {
+		return nil
+	}
+	return t.store.Save(userID, token)
+}
+
+// Source returns the user's token source, loading their tokens from the
+// store the first time. It returns ErrNoToken if there are none.
+func (t *Tokens) Source(userID string) (*Source, error) {
+	t.mu.Lock()
+	source, ok := t.sources[userID]
+	t.mu.Unlock()
+	if ok {
+		return source, nil
+	}
+
+	if t.store == nil || userID == "" {
+		return nil, ErrNoToken
+	}
+	token, err := t.store.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.RefreshToken == "" {
+		return nil, ErrNoToken
+	}
+	return t.sourceFor(userID, *token, time.Time{}), nil
+}
+
+// sourceFor returns the source for id, starting one from token if there is
+// none yet. A source that expires is dropped after then; a zero time keeps it.
+func (t *Tokens) sourceFor(id string, token Token, expires time.Time) *Source {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Another request may have loaded or saved the tokens meanwhile
+	if source, ok := t.sources[id]; ok {
+		return source
+	}
+	t.evictExpired()
+	source := &Source{tokens: t, id: id, token: token, expires: expires}
+	t.sources[id] = source
+	return source
+}
+
+// evictExpired drops the sources that expired, at most once every
+// sessionPruneInterval. t.mu must be held.
+func (t *Tokens) evictExpired() {
+	now := t.now()
+	if now.Before(t.nextEviction) {
+		return
+	}
+	t.nextEviction = now.Add(sessionPruneInterval)
+
+	for id, source := range t.sources {
+		if !source.expires.IsZero() && !now.Before(source.expires) {
+			delete(t.sources, id)
+		}
+	}
+}
+
+// forget drops the source for id, e.g. when its session ends
+func (t *Tokens) forget(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sources, id)
+}
+
+// Source is a spotify.TokenSource for one user or session. Refreshed tokens
+// are written back to the store.
+type Source struct {
+	tokens  *Tokens
+	id      string
+	expires time.Time // when its session ends, zero for a user's
+
+	mu    sync.Mutex
+	token Token
+}
+
+// Token returns the access token, refreshing it first if it expires
+// within RefreshMargin
+func (s *Source) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiring() {
+		if err := s.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return s.token.AccessToken, nil
+}
+
+// Refresh replaces token, which Spotify rejected, unless that already
+// happened, and returns the current access token
+func (s *Source) Refresh(ctx context.Context, token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == s.token.AccessToken {
+		if err := s.refresh(ctx); err != nil {
+			return "", err
+		}
+	}
+	return s.token.AccessToken, nil
+}
+
+func (s *Source) expiring() bool {
+	return !s.tokens.now().Add(RefreshMargin).Before(s.token.ExpiresAt)
+}
+
+// refresh trades the refresh token for a new access token. If Spotify turns
+// the refresh token down, another instance may have been handed a new one
+// already, so the stored tokens are tried before giving up.
+func (s *Source) refresh(ctx context.Context) error {
+	if s.token.RefreshToken == "" {
+		return ErrNoToken
+	}
+
+	resp, err := s.tokens.config.RefreshAccessToken(ctx, s.token.RefreshToken)
+	if err != nil {
+		stored := s.tokens.load(s.id)
+		if stored == nil || stored.RefreshToken == s.token.RefreshToken {
+			return err
+		}
+		s.token = *stored
+		if !s.expiring() {
+			return nil
+		}
+		if resp, err = s.tokens.config.RefreshAccessToken(ctx, s.token.RefreshToken); err != nil {
+			return err
+		}
+	}
+
+	token := NewToken(resp, s.tokens.now())
+	// Spotify only sometimes rotates the refresh token
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.token.RefreshToken
+	}
+	s.token = token
+
+	if s.tokens.store != nil {
+		// The new token works for this instance even if it can't be stored
+		if err := s.tokens.store.Save(s.id, token); err != nil {
+			log.Printf("auth: failed to store refreshed token for %s: %v", s.id, err)
+		}
+	}
+	return nil
+}
+
+// load reads the tokens stored for id, returning nil if there are none or
+// they can't be read
+func (t *Tokens) load(id string) *Token {
+	if t.store == nil {
+		return nil
+	}
+	token, err := t.store.Load(id)
+	if err != nil {
+		log.Printf("auth: failed to load tokens for %s: %v", id, err)
+		return nil
+	}
+	return token
+}
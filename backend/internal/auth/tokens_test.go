@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+// memoryTokenStore stands in for the users table
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+	saves  int
+}
+
+func (m *memoryTokenStore) Load(userID string) (*Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (m *memoryTokenStore) Save(userID string, token Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[userID] = token
+	m.saves++
+	return nil
+}
+
+// signIn goes through the authorization code exchange for userID
+func signIn(t *testing.T, srv *spotifytest.Server, tokens *Tokens, userID string) Token {
+	t.Helper()
+
+	srv.AddAuthCode("code-"+userID, userID)
+	resp, err := srv.Config().ExchangeCode(context.Background(), "code-"+userID)
+	if err != nil {
+		t.Fatalf("exchange code: %v", err)
+	}
+	token := NewToken(resp, tokens.now())
+	if err := tokens.Save(userID, token); err != nil {
+		t.Fatalf("save tokens: %v", err)
+	}
+	return token
+}
+
+func TestSourceRefreshesBeforeExpiry(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+
+	store := &memoryTokenStore{tokens: make(map[string]Token)}
+	tokens := NewTokens(srv.Config(), store)
+	signedIn := signIn(t, srv, tokens, "alice")
+
+	source, err := tokens.Source("alice")
+	if err != nil {
+		t.Fatalf("source: %v", err)
+	}
+	ctx := context.Background()
+
+	if token, _ := source.Token(ctx); token != signedIn.AccessToken {
+		t.Errorf("expected the fresh token to be used as is, got %q", token)
+	}
+
+	now := signedIn.ExpiresAt.Add(-RefreshMargin + time.Second)
+	tokens.now = func() time.Time { return now }
+
+	token, err := source.Token(ctx)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	if token == signedIn.AccessToken {
+		t.Fatalf("expected the token to be refreshed before it expires")
+	}
+	stored, _ := store.Load("alice")
+	if stored.AccessToken != token || stored.RefreshToken != signedIn.RefreshToken || !stored.ExpiresAt.After(now) {
+		t.Errorf("expected the refreshed token to be stored with the same refresh token, got %+v", stored)
+	}
+
+	// After a restart the stored tokens are picked up again
+	restarted := NewTokens(srv.Config(), store)
+	source, err = restarted.Source("alice")
+	if err != nil {
+		t.Fatalf("source after restart: %v", err)
+	}
+	if got, _ := source.Token(ctx); got != token {
+		t.Errorf("expected the stored token after a restart, got %q", got)
+	}
+
+	if _, err := restarted.Source("bob"); err != ErrNoToken {
+		t.Errorf("expected ErrNoToken for a user who never signed in, got %v", err)
+	}
+}
+
+func TestClientRefreshesTokenMidJob(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+
+	store := &memoryTokenStore{tokens: make(map[string]Token)}
+	tokens := NewTokens(srv.Config(), store)
+	signedIn := signIn(t, srv, tokens, "alice")
+	source, _ := tokens.Source("alice")
+	client := srv.Config().NewClientWithTokens(source)
+	ctx := context.Background()
+
+	if _, err := client.GetUserProfile(ctx); err != nil {
+		t.Fatalf("profile: %v", err)
+	}
+
+	// Spotify drops the token halfway through, as it does when one expires
+	srv.RevokeToken(signedIn.AccessToken)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetUserProfile(ctx); err != nil {
+				t.Errorf("profile after revoke: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	token, _ := source.Token(ctx)
+	if token == signedIn.AccessToken {
+		t.Errorf("expected a new access token")
+	}
+
+	// Concurrent requests rejected together refresh once between them
+	if store.saves != 2 {
+		t.Errorf("expected the sign-in and a single refresh to be stored, got %d saves", store.saves)
+	}
+}
@@ -0,0 +1,274 @@
+// Package autosync syncs the playlists of users who opted in in the
+// background, on an hourly, daily or weekly schedule.
+package autosync
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+const (
+	// TickInterval is how often the scheduler looks for users that are due
+	TickInterval = time.Minute
+
+	// RunTimeout bounds a single user's sync
+	RunTimeout = 30 * time.Minute
+
+	// MaxRunning caps how many runs are in progress at once. Runs also wait
+	// for a free jobs worker, so this only bounds how many are queued there.
+	MaxRunning = 16
+
+	// DefaultRetention is how long run records are kept
+	DefaultRetention = 30 * 24 * time.Hour
+)
+
+// ErrSkipped is returned by a SyncFunc that didn't sync, e.g. because the
+// user already had a job running. The run is recorded as skipped.
+var ErrSkipped = errors.New("auto-sync skipped")
+
+// SyncFunc syncs the user's playlists for run, filling in its counts
+type SyncFunc func(ctx context.Context, run *models.AutoSyncRun) error
+
+// Store is where subscriptions are read and runs are recorded
+type Store interface {
+	// Subscribers returns the settings of every user who opted in
+	Subscribers() ([]models.UserSettings, error)
+
+	// Claim records that run is starting. It returns false if the user's run
+	// for the same slot was already claimed, by this or another process.
+	Claim(run *models.AutoSyncRun) (bool, error)
+
+	// Finish saves the run's outcome
+	Finish(run *models.AutoSyncRun) error
+
+	// DeleteBefore removes runs started before cutoff
+	DeleteBefore(cutoff time.Time) (int64, error)
+}
+
+// Scheduler runs each subscriber's sync once per period of their schedule.
+// Every user is given a fixed offset into the period, derived from their ID,
+// so runs are spread evenly across the hour rather than all starting on it.
+// A user is only due once a slot starts after the scheduler first saw them
+// subscribed, so neither starting up nor opting in runs everyone at once.
+type Scheduler struct {
+	store     Store
+	sync      SyncFunc
+	retention time.Duration
+	now       func() time.Time
+
+	running chan struct{} // one token per run in progress
+	runs    sync.WaitGroup
+
+	mu      sync.Mutex
+	seen    map[string]time.Time // when each subscriber was first seen
+	claimed map[string]time.Time // last slot claimed, by user ID
+}
+
+// New returns a scheduler that records runs in store and syncs with sync.
+// Runs older than retention are pruned; zero means DefaultRetention.
+func New(store Store, sync SyncFunc, retention time.Duration) *Scheduler {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Scheduler{
+		store:     store,
+		sync:      sync,
+		retention: retention,
+		now:       time.Now,
+		running:   make(chan struct{}, MaxRunning),
+		seen:      make(map[string]time.Time),
+		claimed:   make(map[string]time.Time),
+	}
+}
+
+// Slot returns the start of the period a user's run for now belongs to.
+// Periods are interval long and offset by a whole number of minutes that
+// depends only on the user ID.
+func Slot(userID string, interval time.Duration, now time.Time) time.Time {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	offset := time.Duration(uint64(h.Sum32())%uint64(interval/time.Minute)) * time.Minute
+
+	return now.Add(-offset).Truncate(interval).Add(offset)
+}
+
+// Run checks for due users straight away and then every TickInterval, and
+// prunes old runs once a day, until ctx is done. It returns once the runs it
+// started have stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	defer s.Wait()
+
+	var pruned time.Time
+	for {
+		if s.now().Sub(pruned) >= 24*time.Hour {
+			s.prune()
+			pruned = s.now()
+		}
+		s.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims the run of every subscriber who is due and hasn't been
+// claimed for the current slot yet, and starts it in the background. It
+// returns how many runs it started. Once MaxRunning runs are in progress the
+// remaining users are left for the next tick.
+func (s *Scheduler) RunDue(ctx context.Context) int {
+	subscribers, err := s.store.Subscribers()
+	if err != nil {
+		log.Printf("auto-sync: failed to load subscribers: %v", err)
+		return 0
+	}
+	s.see(subscribers)
+
+	started := 0
+	for _, settings := range subscribers {
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case s.running <- struct{}{}:
+		default:
+			log.Printf("auto-sync: %d runs in progress, leaving the rest for the next tick", MaxRunning)
+			return started
+		}
+
+		run := s.claim(settings)
+		if run == nil {
+			<-s.running
+			continue
+		}
+
+		s.runs.Add(1)
+		go func() {
+			defer s.runs.Done()
+			defer func() { <-s.running }()
+			s.execute(ctx, run)
+		}()
+		started++
+	}
+	return started
+}
+
+// Wait blocks until the runs started so far have finished
+func (s *Scheduler) Wait() {
+	s.runs.Wait()
+}
+
+// see notes when each subscriber was first seen and forgets users who opted
+// out, so they aren't due straight away when they opt in again. A user seen
+// on the first tick is counted as seen a tick earlier, so one whose slot
+// started just before the scheduler did still runs.
+func (s *Scheduler) see(subscribers []models.UserSettings) {
+	now := s.now()
+	subscribed := make(map[string]bool, len(subscribers))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, settings := range subscribers {
+		subscribed[settings.UserID] = true
+		if _, ok := s.seen[settings.UserID]; !ok {
+			s.seen[settings.UserID] = now.Add(-TickInterval)
+		}
+	}
+	for userID := range s.seen {
+		if !subscribed[userID] {
+			delete(s.seen, userID)
+		}
+	}
+}
+
+// claim returns the user's run for the current slot, or nil if they aren't
+// due or the run was claimed elsewhere
+func (s *Scheduler) claim(settings models.UserSettings) *models.AutoSyncRun {
+	interval := models.AutoSyncInterval(settings.AutoSync)
+	if interval == 0 {
+		return nil
+	}
+
+	now := s.now()
+	slot := Slot(settings.UserID, interval, now)
+
+	s.mu.Lock()
+	// Wait for the user's offset into a period that started after they were
+	// first seen, rather than catching up on the one in progress
+	if !slot.After(s.seen[settings.UserID]) || !s.claimed[settings.UserID].Before(slot) {
+		s.mu.Unlock()
+		return nil
+	}
+	s.claimed[settings.UserID] = slot
+	s.mu.Unlock()
+
+	run := &models.AutoSyncRun{
+		ID:           uuid.New().String(),
+		UserID:       settings.UserID,
+		Schedule:     settings.AutoSync,
+		ScheduledFor: slot,
+		Status:       models.AutoSyncRunning,
+		StartedAt:    now,
+	}
+
+	claimed, err := s.store.Claim(run)
+	if err != nil {
+		// Try again on the next tick
+		log.Printf("auto-sync: failed to claim run for %s: %v", settings.UserID, err)
+		s.mu.Lock()
+		delete(s.claimed, settings.UserID)
+		s.mu.Unlock()
+		return nil
+	}
+	if !claimed {
+		return nil
+	}
+	return run
+}
+
+func (s *Scheduler) execute(ctx context.Context, run *models.AutoSyncRun) {
+	runCtx, cancel := context.WithTimeout(ctx, RunTimeout)
+	defer cancel()
+
+	err := s.sync(runCtx, run)
+
+	finished := s.now()
+	run.FinishedAt = &finished
+	switch {
+	case errors.Is(err, ErrSkipped):
+		run.Status = models.AutoSyncSkipped
+		run.ErrorMessage = err.Error()
+	case err != nil:
+		run.Status = models.AutoSyncFailed
+		run.ErrorMessage = err.Error()
+		log.Printf("auto-sync for %s failed: %v", run.UserID, err)
+	default:
+		run.Status = models.AutoSyncSucceeded
+	}
+
+	if err := s.store.Finish(run); err != nil {
+		log.Printf("auto-sync: failed to record run %s for %s: %v", run.ID, run.UserID, err)
+	}
+}
+
+func (s *Scheduler) prune() {
+	deleted, err := s.store.DeleteBefore(s.now().Add(-s.retention))
+	if err != nil {
+		log.Printf("auto-sync: failed to prune runs: %v", err)
+	} else if deleted > 0 {
+		log.Printf("auto-sync: pruned %d runs older than %s", deleted, s.retention)
+	}
+}
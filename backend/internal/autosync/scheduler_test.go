@@ -0,0 +1,294 @@
+package autosync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// memoryStore stands in for user_settings and auto_sync_runs
+type memoryStore struct {
+	mu          sync.Mutex
+	subscribers []models.UserSettings
+	runs        map[string]*models.AutoSyncRun // by user ID and slot
+}
+
+func newMemoryStore(subscribers ...models.UserSettings) *memoryStore {
+	return &memoryStore{subscribers: subscribers, runs: make(map[string]*models.AutoSyncRun)}
+}
+
+func runKey(userID string, slot time.Time) string {
+	return userID + "@" + slot.UTC().Format(time.RFC3339)
+}
+
+func (m *memoryStore) Subscribers() ([]models.UserSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.UserSettings(nil), m.subscribers...), nil
+}
+
+func (m *memoryStore) Claim(run *models.AutoSyncRun) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := runKey(run.UserID, run.ScheduledFor)
+	if _, ok := m.runs[key]; ok {
+		return false, nil
+	}
+	stored := *run
+	m.runs[key] = &stored
+	return true, nil
+}
+
+func (m *memoryStore) Finish(run *models.AutoSyncRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *run
+	m.runs[runKey(run.UserID, run.ScheduledFor)] = &stored
+	return nil
+}
+
+func (m *memoryStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key, run := range m.runs {
+		if run.StartedAt.Before(cutoff) {
+			delete(m.runs, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *memoryStore) runsFor(userID string) []*models.AutoSyncRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var runs []*models.AutoSyncRun
+	for _, run := range m.runs {
+		if run.UserID == userID {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+func subscriber(userID, schedule string) models.UserSettings {
+	settings := models.DefaultSettings(userID)
+	settings.AutoSync = schedule
+	return *settings
+}
+
+// subscribedSince makes the scheduler treat users as seen at since, as if it
+// had been running since then
+func subscribedSince(s *Scheduler, since time.Time, userIDs ...string) {
+	for _, userID := range userIDs {
+		s.seen[userID] = since
+	}
+}
+
+func TestSlotSpreadsUsersAcrossTheHour(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 34, 56, 0, time.UTC)
+	minutes := make(map[int]bool)
+
+	for i := 0; i < 600; i++ {
+		slot := Slot(fmt.Sprintf("user%d", i), time.Hour, now)
+		if slot.After(now) || !now.Before(slot.Add(time.Hour)) {
+			t.Fatalf("slot %s doesn't contain %s", slot, now)
+		}
+		minutes[slot.Minute()] = true
+	}
+
+	if len(minutes) < 55 {
+		t.Errorf("expected runs spread over most minutes of the hour, got %d distinct minutes", len(minutes))
+	}
+}
+
+func TestRunDueRunsEachUserOncePerSlot(t *testing.T) {
+	store := newMemoryStore(
+		subscriber("alice", models.AutoSyncHourly),
+		subscriber("bob", models.AutoSyncDaily),
+		subscriber("carol", models.AutoSyncOff),
+	)
+
+	var mu sync.Mutex
+	synced := make(map[string]int)
+	scheduler := New(store, func(ctx context.Context, run *models.AutoSyncRun) error {
+		mu.Lock()
+		synced[run.UserID]++
+		mu.Unlock()
+		return nil
+	}, 0)
+
+	// Start just inside bob's day so an hour later he still isn't due
+	now := Slot("bob", 24*time.Hour, time.Now()).Add(time.Minute)
+	scheduler.now = func() time.Time { return now }
+	subscribedSince(scheduler, now.Add(-48*time.Hour), "alice", "bob", "carol")
+
+	if ran := scheduler.RunDue(context.Background()); ran != 2 {
+		t.Fatalf("expected alice and bob to run, got %d runs", ran)
+	}
+	scheduler.Wait()
+	if ran := scheduler.RunDue(context.Background()); ran != 0 {
+		t.Errorf("expected nothing due again in the same slot, got %d runs", ran)
+	}
+
+	// Another replica sharing the store doesn't run them a second time
+	replica := New(store, scheduler.sync, 0)
+	replica.now = scheduler.now
+	subscribedSince(replica, now.Add(-48*time.Hour), "alice", "bob", "carol")
+	if ran := replica.RunDue(context.Background()); ran != 0 {
+		t.Errorf("expected claimed runs not to run on another replica, got %d runs", ran)
+	}
+
+	now = now.Add(time.Hour)
+	if ran := scheduler.RunDue(context.Background()); ran != 1 {
+		t.Errorf("expected only alice an hour later, got %d runs", ran)
+	}
+	scheduler.Wait()
+
+	if synced["alice"] != 2 || synced["bob"] != 1 || synced["carol"] != 0 {
+		t.Errorf("unexpected syncs %v", synced)
+	}
+}
+
+func TestRunDueRecordsOutcomes(t *testing.T) {
+	store := newMemoryStore(
+		subscriber("alice", models.AutoSyncHourly),
+		subscriber("bob", models.AutoSyncHourly),
+		subscriber("carol", models.AutoSyncHourly),
+	)
+
+	scheduler := New(store, func(ctx context.Context, run *models.AutoSyncRun) error {
+		switch run.UserID {
+		case "alice":
+			run.PlaylistsUpdated = 3
+			run.TracksAdded = 12
+			return nil
+		case "bob":
+			return fmt.Errorf("a playlist sync is already running: %w", ErrSkipped)
+		default:
+			return errors.New("token refresh failed: 400")
+		}
+	}, 0)
+	subscribedSince(scheduler, time.Now().Add(-2*time.Hour), "alice", "bob", "carol")
+	scheduler.RunDue(context.Background())
+	scheduler.Wait()
+
+	tests := []struct {
+		userID string
+		status string
+	}{
+		{"alice", models.AutoSyncSucceeded},
+		{"bob", models.AutoSyncSkipped},
+		{"carol", models.AutoSyncFailed},
+	}
+	for _, tt := range tests {
+		runs := store.runsFor(tt.userID)
+		if len(runs) != 1 {
+			t.Fatalf("expected 1 run for %s, got %d", tt.userID, len(runs))
+		}
+		if runs[0].Status != tt.status || runs[0].FinishedAt == nil {
+			t.Errorf("expected %s's run to finish as %s, got %+v", tt.userID, tt.status, runs[0])
+		}
+	}
+
+	if run := store.runsFor("alice")[0]; run.PlaylistsUpdated != 3 || run.TracksAdded != 12 {
+		t.Errorf("expected alice's counts to be recorded, got %+v", run)
+	}
+	if run := store.runsFor("carol")[0]; run.ErrorMessage != "token refresh failed: 400" {
+		t.Errorf("expected carol's error to be recorded, got %q", run.ErrorMessage)
+	}
+}
+
+func TestRunDueWaitsForEachUsersOffset(t *testing.T) {
+	var subscribers []models.UserSettings
+	for i := 0; i < 120; i++ {
+		subscribers = append(subscribers, subscriber(fmt.Sprintf("user%d", i), models.AutoSyncHourly))
+	}
+	scheduler := New(newMemoryStore(subscribers...), func(ctx context.Context, run *models.AutoSyncRun) error {
+		return nil
+	}, 0)
+
+	now := time.Now()
+	scheduler.now = func() time.Time { return now }
+
+	// On startup only users whose slot has only just started are due
+	due := 0
+	for _, settings := range subscribers {
+		if Slot(settings.UserID, time.Hour, now).After(now.Add(-TickInterval)) {
+			due++
+		}
+	}
+	if ran := scheduler.RunDue(context.Background()); ran != due {
+		t.Errorf("expected %d of %d users to run on startup, got %d runs", due, len(subscribers), ran)
+	}
+	scheduler.Wait()
+
+	// Over the rest of the hour everyone else reaches their offset, a minute
+	// at a time
+	ran := due
+	for i := 1; i < 60; i++ {
+		now = now.Add(TickInterval)
+		started := scheduler.RunDue(context.Background())
+		if started > len(subscribers)/10 {
+			t.Errorf("expected runs to be spread across the hour, got %d at once", started)
+		}
+		ran += started
+		scheduler.Wait()
+	}
+	if ran != len(subscribers) {
+		t.Errorf("expected every user to run once within the hour, got %d runs", ran)
+	}
+}
+
+func TestRunDueDoesNotWaitForSlowRuns(t *testing.T) {
+	store := newMemoryStore(
+		subscriber("alice", models.AutoSyncHourly),
+		subscriber("bob", models.AutoSyncHourly),
+	)
+
+	release := make(chan struct{})
+	bobSynced := make(chan struct{})
+	scheduler := New(store, func(ctx context.Context, run *models.AutoSyncRun) error {
+		if run.UserID == "alice" {
+			<-release
+		} else {
+			close(bobSynced)
+		}
+		return nil
+	}, 0)
+	subscribedSince(scheduler, time.Now().Add(-2*time.Hour), "alice", "bob")
+
+	started := make(chan int)
+	go func() { started <- scheduler.RunDue(context.Background()) }()
+
+	select {
+	case ran := <-started:
+		if ran != 2 {
+			t.Errorf("expected alice and bob to run, got %d runs", ran)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected RunDue to return while alice's run is still going")
+	}
+
+	select {
+	case <-bobSynced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected bob to be synced while alice's run is still going")
+	}
+
+	close(release)
+	scheduler.Wait()
+	if runs := store.runsFor("alice"); len(runs) != 1 || runs[0].Status != models.AutoSyncSucceeded {
+		t.Errorf("expected alice's run to finish, got %+v", runs)
+	}
+}
@@ -0,0 +1,37 @@
+package autosync
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// DatabaseStore reads subscriptions from user_settings and records runs in
+// auto_sync_runs, whose unique slot makes claims safe across replicas
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Subscribers() ([]models.UserSettings, error) {
+	return database.GetAutoSyncSubscribers()
+}
+
+func (s *DatabaseStore) Claim(run *models.AutoSyncRun) (bool, error) {
+	err := database.CreateAutoSyncRun(run)
+	if errors.Is(err, database.ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *DatabaseStore) Finish(run *models.AutoSyncRun) error {
+	return database.UpdateAutoSyncRun(run)
+}
+
+func (s *DatabaseStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	return database.DeleteAutoSyncRunsBefore(cutoff)
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// artistBatchSize keeps the IDs of a lookup from making the URL too long
+const artistBatchSize = 100
+
+// GetArtistGenres returns the cached genres of the given artists. Artists
+// that were never cached are left out.
+func GetArtistGenres(artistIDs []string) ([]models.ArtistGenres, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	var cached []models.ArtistGenres
+	for start := 0; start < len(artistIDs); start += artistBatchSize {
+		end := min(start+artistBatchSize, len(artistIDs))
+		res, _, err := Client.From("artist_genres").
+			Select("*", "", false).
+			In("artist_id", artistIDs[start:end]).
+			Execute()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []models.ArtistGenres
+		if err := json.Unmarshal(res, &batch); err != nil {
+			return nil, err
+		}
+		cached = append(cached, batch...)
+	}
+
+	return cached, nil
+}
+
+// UpsertArtistGenres caches the genres of artists, replacing older copies
+func UpsertArtistGenres(entries []models.ArtistGenres) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	for start := 0; start < len(entries); start += libraryPageSize {
+		end := min(start+libraryPageSize, len(entries))
+		_, _, err := Client.From("artist_genres").
+			Upsert(entries[start:end], "artist_id", "minimal", "").
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/supabase-community/postgrest-go"
+)
+
+// ErrDuplicate is returned when an insert conflicts with an existing row
+var ErrDuplicate = errors.New("duplicate row")
+
+// isUniqueViolation reports whether err is Postgres' unique_violation, which
+// postgrest-go only exposes through the error text
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "(23505)")
+}
+
+// GetAutoSyncSubscribers returns the settings of every user who opted in to
+// auto-sync
+func GetAutoSyncSubscribers() ([]models.UserSettings, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("user_settings").
+		Select("*", "", false).
+		Neq("auto_sync", models.AutoSyncOff).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var settings []models.UserSettings
+	if err := json.Unmarshal(res, &settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// CreateAutoSyncRun inserts a run, returning ErrDuplicate if the user's run
+// for that slot already exists
+func CreateAutoSyncRun(run *models.AutoSyncRun) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("auto_sync_runs").
+		Insert(run, false, "", "minimal", "").
+		Execute()
+
+	if isUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// UpdateAutoSyncRun saves a run's outcome
+func UpdateAutoSyncRun(run *models.AutoSyncRun) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("auto_sync_runs").
+		Update(run, "", "").
+		Eq("id", run.ID).
+		Execute()
+
+	return err
+}
+
+// ListAutoSyncRuns returns a user's runs, newest first
+func ListAutoSyncRuns(userID string, limit int) ([]models.AutoSyncRun, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("auto_sync_runs").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Order("started_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []models.AutoSyncRun
+	if err := json.Unmarshal(res, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// DeleteAutoSyncRunsBefore removes runs started before cutoff and returns how
+// many were deleted
+func DeleteAutoSyncRunsBefore(cutoff time.Time) (int64, error) {
+	if Client == nil {
+		return 0, ErrNotInitialized
+	}
+
+	_, count, err := Client.From("auto_sync_runs").
+		Delete("minimal", "exact").
+		Lt("started_at", cutoff.UTC().Format(time.RFC3339)).
+		Execute()
+
+	return count, err
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/supabase-community/postgrest-go"
+)
+
+// CreateOrganizeJob inserts a new organize job
+func CreateOrganizeJob(job *models.OrganizeJob) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("organize_jobs").
+		Insert(job, false, "", "", "").
+		Execute()
+
+	return err
+}
+
+// UpdateOrganizeJob saves a job's progress and outcome
+func UpdateOrganizeJob(job *models.OrganizeJob) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+	job.UpdatedAt = time.Now()
+
+	_, _, err := Client.From("organize_jobs").
+		Update(job, "", "").
+		Eq("id", job.ID).
+		Execute()
+
+	return err
+}
+
+// GetOrganizeJob fetches a job by ID, returning nil if it doesn't exist
+func GetOrganizeJob(jobID string) (*models.OrganizeJob, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("organize_jobs").
+		Select("*", "", false).
+		Eq("id", jobID).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []models.OrganizeJob
+	if err := json.Unmarshal(res, &jobs); err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	return &jobs[0], nil
+}
+
+// ListOrganizeJobs returns a user's jobs, newest first
+func ListOrganizeJobs(userID string, limit int) ([]models.OrganizeJob, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("organize_jobs").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []models.OrganizeJob
+	if err := json.Unmarshal(res, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// DeleteOrganizeJobsBefore removes jobs created before cutoff and returns how
+// many were deleted
+func DeleteOrganizeJobsBefore(cutoff time.Time) (int64, error) {
+	if Client == nil {
+		return 0, ErrNotInitialized
+	}
+
+	_, count, err := Client.From("organize_jobs").
+		Delete("minimal", "exact").
+		Lt("created_at", cutoff.UTC().Format(time.RFC3339)).
+		Execute()
+
+	return count, err
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/supabase-community/postgrest-go"
+)
+
+// libraryPageSize is how many rows are read or written per request; the API
+// caps responses at 1000 rows
+const libraryPageSize = 1000
+
+// GetLibrarySnapshot fetches the state of a user's stored library, returning
+// nil if it was never fetched
+func GetLibrarySnapshot(userID string) (*models.LibrarySnapshot, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("library_snapshots").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []models.LibrarySnapshot
+	if err := json.Unmarshal(res, &snapshots); err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+
+	return &snapshots[0], nil
+}
+
+// SaveLibrarySnapshot creates or updates the state of a user's stored library
+func SaveLibrarySnapshot(snapshot *models.LibrarySnapshot) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+	snapshot.UpdatedAt = time.Now()
+
+	_, _, err := Client.From("library_snapshots").
+		Upsert(snapshot, "user_id", "", "").
+		Execute()
+
+	return err
+}
+
+// GetLibraryTracks returns a user's stored liked songs, newest first
+func GetLibraryTracks(userID string) ([]models.LibraryTrack, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	var tracks []models.LibraryTrack
+	for from := 0; ; from += libraryPageSize {
+		res, _, err := Client.From("library_tracks").
+			Select("*", "", false).
+			Eq("user_id", userID).
+			Order("added_at", &postgrest.OrderOpts{Ascending: false}).
+			Order("track_id", &postgrest.OrderOpts{Ascending: true}).
+			Range(from, from+libraryPageSize-1, "").
+			Execute()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page []models.LibraryTrack
+		if err := json.Unmarshal(res, &page); err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, page...)
+		if len(page) < libraryPageSize {
+			return tracks, nil
+		}
+	}
+}
+
+// UpsertLibraryTracks stores liked songs, updating ones already stored
+func UpsertLibraryTracks(tracks []models.LibraryTrack) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	for start := 0; start < len(tracks); start += libraryPageSize {
+		end := min(start+libraryPageSize, len(tracks))
+		_, _, err := Client.From("library_tracks").
+			Upsert(tracks[start:end], "user_id,track_id", "minimal", "").
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteLibraryTracks removes songs from a user's stored library
+func DeleteLibraryTracks(userID string, trackIDs []string) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	// Keep the IDs from making the URL too long
+	const batchSize = 100
+	for start := 0; start < len(trackIDs); start += batchSize {
+		end := min(start+batchSize, len(trackIDs))
+		_, _, err := Client.From("library_tracks").
+			Delete("minimal", "").
+			Eq("user_id", userID).
+			In("track_id", trackIDs[start:end]).
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetLibraryArtists returns the artists in a user's stored library
+func GetLibraryArtists(userID string) ([]models.LibraryArtist, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	var artists []models.LibraryArtist
+	for from := 0; ; from += libraryPageSize {
+		res, _, err := Client.From("library_artists").
+			Select("*", "", false).
+			Eq("user_id", userID).
+			Order("artist_id", &postgrest.OrderOpts{Ascending: true}).
+			Range(from, from+libraryPageSize-1, "").
+			Execute()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var page []models.LibraryArtist
+		if err := json.Unmarshal(res, &page); err != nil {
+			return nil, err
+		}
+
+		artists = append(artists, page...)
+		if len(page) < libraryPageSize {
+			return artists, nil
+		}
+	}
+}
+
+// UpsertLibraryArtists stores the artists of a user's library and their
+// genres
+func UpsertLibraryArtists(artists []models.LibraryArtist) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	now := time.Now()
+	for i := range artists {
+		artists[i].UpdatedAt = now
+	}
+
+	for start := 0; start < len(artists); start += libraryPageSize {
+		end := min(start+libraryPageSize, len(artists))
+		_, _, err := Client.From("library_artists").
+			Upsert(artists[start:end], "user_id,artist_id", "minimal", "").
+			Execute()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// CreateOrganizePlan inserts a new organize plan
+func CreateOrganizePlan(plan *models.OrganizePlan) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("organize_plans").
+		Insert(plan, false, "", "", "").
+		Execute()
+
+	return err
+}
+
+// UpdateOrganizePlan saves a plan's edits and status if the stored plan is
+// still at plan.Version, bumping the version. It returns false if the plan
+// was updated by someone else since it was read.
+func UpdateOrganizePlan(plan *models.OrganizePlan) (bool, error) {
+	if Client == nil {
+		return false, ErrNotInitialized
+	}
+
+	updated := *plan
+	updated.Version++
+	updated.UpdatedAt = time.Now()
+
+	_, count, err := Client.From("organize_plans").
+		Update(updated, "minimal", "exact").
+		Eq("id", plan.ID).
+		Eq("version", strconv.Itoa(plan.Version)).
+		Execute()
+	if err != nil || count == 0 {
+		return false, err
+	}
+
+	*plan = updated
+	return true, nil
+}
+
+// GetOrganizePlan fetches a plan by ID, returning nil if it doesn't exist
+func GetOrganizePlan(planID string) (*models.OrganizePlan, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("organize_plans").
+		Select("*", "", false).
+		Eq("id", planID).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []models.OrganizePlan
+	if err := json.Unmarshal(res, &plans); err != nil {
+		return nil, err
+	}
+
+	if len(plans) == 0 {
+		return nil, nil
+	}
+
+	return &plans[0], nil
+}
+
+// DeleteOrganizePlansBefore removes plans created before cutoff and returns
+// how many were deleted
+func DeleteOrganizePlansBefore(cutoff time.Time) (int64, error) {
+	if Client == nil {
+		return 0, ErrNotInitialized
+	}
+
+	_, count, err := Client.From("organize_plans").
+		Delete("minimal", "exact").
+		Lt("created_at", cutoff.UTC().Format(time.RFC3339)).
+		Execute()
+
+	return count, err
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// CreateSession inserts a session. Its tokens are stored as given, which
+// should already be encrypted.
+func CreateSession(session *models.Session) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("sessions").
+		Insert(session, false, "", "minimal", "").
+		Execute()
+
+	return err
+}
+
+// GetSession fetches a session by ID, returning nil if it doesn't exist
+func GetSession(id string) (*models.Session, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("sessions").
+		Select("*", "", false).
+		Eq("id", id).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	if err := json.Unmarshal(res, &sessions); err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	return &sessions[0], nil
+}
+
+// UpdateSessionTokens replaces a session's tokens after they were refreshed
+func UpdateSessionTokens(id, accessToken, refreshToken string, expiresAt time.Time) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("sessions").
+		Update(sessionTokens{
+			AccessToken:    accessToken,
+			RefreshToken:   refreshToken,
+			TokenExpiresAt: expiresAt,
+		}, "", "").
+		Eq("id", id).
+		Execute()
+
+	return err
+}
+
+// DeleteSession removes a session, signing its browser out
+func DeleteSession(id string) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("sessions").
+		Delete("minimal", "").
+		Eq("id", id).
+		Execute()
+
+	return err
+}
+
+// DeleteSessionsExpiredBefore removes sessions that expired before cutoff and
+// returns how many were deleted
+func DeleteSessionsExpiredBefore(cutoff time.Time) (int64, error) {
+	if Client == nil {
+		return 0, ErrNotInitialized
+	}
+
+	_, count, err := Client.From("sessions").
+		Delete("minimal", "exact").
+		Lt("expires_at", cutoff.UTC().Format(time.RFC3339)).
+		Execute()
+
+	return count, err
+}
+
+// sessionTokens is what UpdateSessionTokens writes
+type sessionTokens struct {
+	AccessToken    string    `json:"access_token"`
+	RefreshToken   string    `json:"refresh_token"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+}
@@ -10,6 +10,10 @@ import (
 
 // GetUserSettings fetches settings for a user, returning defaults if not found
 func GetUserSettings(userID string) (*models.UserSettings, error) {
+	if Client == nil {
+		return models.DefaultSettings(userID), nil
+	}
+
 	// Try to fetch from DB
 	res, _, err := Client.From("user_settings").
 		Select("*", "", false).
@@ -33,6 +37,9 @@ func GetUserSettings(userID string) (*models.UserSettings, error) {
 
 // SaveUserSettings upserts user settings
 func SaveUserSettings(settings *models.UserSettings) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
 	settings.UpdatedAt = time.Now()
 
 	_, _, err := Client.From("user_settings").
@@ -44,6 +51,10 @@ func SaveUserSettings(settings *models.UserSettings) error {
 
 // GetPlaylistOverrides fetches all overrides for a user
 func GetPlaylistOverrides(userID string) (map[string]*models.PlaylistOverride, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
 	res, _, err := Client.From("playlist_overrides").
 		Select("*", "", false).
 		Eq("user_id", userID).
@@ -66,12 +77,36 @@ func GetPlaylistOverrides(userID string) (map[string]*models.PlaylistOverride, e
 	return result, nil
 }
 
-// SavePlaylistOverride upserts a playlist override
+// SavePlaylistOverride upserts a playlist override, keyed on the user and
+// playlist. Every column is written, so update an override that was read
+// rather than saving a fresh one over it.
 func SavePlaylistOverride(override *models.PlaylistOverride) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
 	override.UpdatedAt = time.Now()
+	if override.CreatedAt.IsZero() {
+		override.CreatedAt = override.UpdatedAt
+	}
+	override.SyncPolicy = override.Policy()
 
 	_, _, err := Client.From("playlist_overrides").
-		Upsert(override, "", "", "").
+		Upsert(override, "user_id,playlist_spotify_id", "", "").
+		Execute()
+
+	return err
+}
+
+// DeletePlaylistOverride forgets a playlist, e.g. once it has been deleted
+func DeletePlaylistOverride(userID, playlistID string) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("playlist_overrides").
+		Delete("minimal", "").
+		Eq("user_id", userID).
+		Eq("playlist_spotify_id", playlistID).
 		Execute()
 
 	return err
@@ -79,6 +114,10 @@ func SavePlaylistOverride(override *models.PlaylistOverride) error {
 
 // GetOldestSyncTimestamp returns the oldest last_synced_at from user's playlist overrides
 func GetOldestSyncTimestamp(userID string) (*time.Time, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
 	res, _, err := Client.From("playlist_overrides").
 		Select("last_synced_at", "", false).
 		Eq("user_id", userID).
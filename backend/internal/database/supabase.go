@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"os"
 
 	"github.com/supabase-community/supabase-go"
@@ -8,6 +9,10 @@ import (
 
 var Client *supabase.Client
 
+// ErrNotInitialized is returned when Init has not connected a client, e.g.
+// in offline tests or when Supabase was unreachable at startup
+var ErrNotInitialized = errors.New("database not initialized")
+
 func Init() error {
 	url := os.Getenv("SUPABASE_URL")
 	key := os.Getenv("SUPABASE_KEY")
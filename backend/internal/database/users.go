@@ -0,0 +1,109 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// UpsertUser creates or updates a user's profile, keyed on their Spotify ID.
+// Their tokens are left alone; see SaveUserTokens.
+func UpsertUser(user *models.User) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+	user.UpdatedAt = time.Now()
+
+	_, _, err := Client.From("users").
+		Upsert(userRow(user), "spotify_id", "minimal", "").
+		Execute()
+
+	return err
+}
+
+// SaveUserTokens stores a user's tokens as given, which should already be
+// encrypted
+func SaveUserTokens(spotifyID, accessToken, refreshToken string, expiresAt time.Time) error {
+	if Client == nil {
+		return ErrNotInitialized
+	}
+
+	_, _, err := Client.From("users").
+		Update(userTokens{
+			AccessToken:    accessToken,
+			RefreshToken:   refreshToken,
+			TokenExpiresAt: expiresAt,
+			UpdatedAt:      time.Now(),
+		}, "", "").
+		Eq("spotify_id", spotifyID).
+		Execute()
+
+	return err
+}
+
+// GetUser fetches a user by Spotify ID, returning nil if they don't exist.
+// Tokens are returned as stored.
+func GetUser(spotifyID string) (*models.User, error) {
+	if Client == nil {
+		return nil, ErrNotInitialized
+	}
+
+	res, _, err := Client.From("users").
+		Select("*", "", false).
+		Eq("spotify_id", spotifyID).
+		Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []userColumns
+	if err := json.Unmarshal(res, &rows); err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	user := rows[0].User
+	user.AccessToken = rows[0].AccessToken
+	user.RefreshToken = rows[0].RefreshToken
+	user.TokenExpiresAt = rows[0].TokenExpiresAt
+	return &user, nil
+}
+
+// userColumns adds the token columns models.User keeps out of its JSON
+type userColumns struct {
+	models.User
+	AccessToken    string     `json:"access_token"`
+	RefreshToken   string     `json:"refresh_token"`
+	TokenExpiresAt *time.Time `json:"token_expires_at"`
+}
+
+// userUpsert is what UpsertUser writes, leaving the generated ID, created_at
+// and the tokens alone
+type userUpsert struct {
+	SpotifyID   string    `json:"spotify_id"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func userRow(user *models.User) userUpsert {
+	return userUpsert{
+		SpotifyID:   user.SpotifyID,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// userTokens is what SaveUserTokens writes
+type userTokens struct {
+	AccessToken    string    `json:"access_token"`
+	RefreshToken   string    `json:"refresh_token"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"sync"
+)
+
+// Event types published while an organize job runs
+const (
+	EventStage    = "stage"    // Data: StageEvent
+	EventProgress = "progress" // Data: ProgressEvent
+	EventGenres   = "genres"   // Data: GenresEvent
+	EventPlaylist = "playlist" // Data: models.JobPlaylist
+)
+
+// Event is a single progress update for a job
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+type StageEvent struct {
+	Stage string `json:"stage"`
+}
+
+type ProgressEvent struct {
+	SongsProcessed int `json:"songs_processed"`
+	TotalSongs     int `json:"total_songs"`
+}
+
+type GenresEvent struct {
+	GenresDiscovered []string `json:"genres_discovered"`
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const subscriberBuffer = 64
+
+// Broker fans out events from running jobs to subscribers in this process.
+// Publishing never blocks: a subscriber that can't keep up misses events and
+// should re-read the job once its channel closes.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving the job's events. The channel is
+// closed when the job finishes (see Close) or unsubscribe is called.
+func (b *Broker) Subscribe(jobID string) (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[jobID] == nil {
+		b.subs[jobID] = make(map[chan Event]struct{})
+	}
+	b.subs[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[jobID][ch]; ok {
+			delete(b.subs[jobID], ch)
+			if len(b.subs[jobID]) == 0 {
+				delete(b.subs, jobID)
+			}
+			close(ch)
+		}
+	}
+}
+
+// Publish sends an event to every subscriber of the job
+func (b *Broker) Publish(jobID string, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[jobID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions to the job are open
+func (b *Broker) Subscribers(jobID string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[jobID])
+}
+
+// Close ends every subscription to the job. Call it once the job's final
+// state has been saved.
+func (b *Broker) Close(jobID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[jobID] {
+		close(ch)
+	}
+	delete(b.subs, jobID)
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"testing"
+)
+
+func TestBrokerDeliversUntilClosed(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe("job1")
+	defer unsubscribe()
+
+	other, unsubscribeOther := b.Subscribe("job2")
+	defer unsubscribeOther()
+
+	b.Publish("job1", Event{Type: EventStage, Data: StageEvent{Stage: "fetching"}})
+	b.Close("job1")
+
+	event, ok := <-events
+	if !ok || event.Type != EventStage {
+		t.Fatalf("expected stage event, got %+v (ok=%v)", event, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed after Close")
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("event leaked to another job's subscriber: %+v", event)
+	default:
+	}
+}
+
+func TestBrokerDropsEventsForSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe("job1")
+
+	// Publishing must not block even when nobody is reading
+	for i := 0; i < subscriberBuffer*2; i++ {
+		b.Publish("job1", Event{Type: EventProgress, Data: ProgressEvent{SongsProcessed: i}})
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("expected %d buffered events, got %d", subscriberBuffer, len(events))
+	}
+
+	unsubscribe()
+	unsubscribe() // safe to call twice
+	b.Close("job1")
+}
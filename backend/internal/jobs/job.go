@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// Job is the live state of an organize job running in this process. The
+// worker changes it only through Update, and everyone else reads copies from
+// Snapshot, so progress can be reported while the job is running.
+type Job struct {
+	mu     sync.Mutex
+	record *models.OrganizeJob
+}
+
+// NewJob wraps a copy of record
+func NewJob(record *models.OrganizeJob) *Job {
+	return &Job{record: copyJob(record)}
+}
+
+// Update applies fn to the job atomically and returns a snapshot of the
+// result. fn must not keep the pointer it is given.
+func (j *Job) Update(fn func(record *models.OrganizeJob)) *models.OrganizeJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(j.record)
+	j.record.UpdatedAt = time.Now()
+	return copyJob(j.record)
+}
+
+// Snapshot returns a copy of the job's current state that the caller owns
+func (j *Job) Snapshot() *models.OrganizeJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return copyJob(j.record)
+}
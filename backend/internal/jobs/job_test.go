@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// Run with -race: progress updates and status reads happen concurrently, as
+// they do between processOrganizeJob and the status/events handlers.
+func TestJobConcurrentUpdatesAndReads(t *testing.T) {
+	job := NewJob(&models.OrganizeJob{ID: "job1", UserID: "alice", Status: models.JobStatusProcessing})
+
+	const updates = 200
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			job.Update(func(r *models.OrganizeJob) {
+				r.SongsProcessed = i
+				r.TotalSongs = updates
+				r.GenresDiscovered = append(r.GenresDiscovered, fmt.Sprintf("genre %d", i))
+				r.PlaylistsCreated = append(r.PlaylistsCreated, models.JobPlaylist{Genre: fmt.Sprintf("genre %d", i)})
+			})
+		}
+		job.Update(func(r *models.OrganizeJob) {
+			r.Stage = "done"
+			r.Status = models.JobStatusCompleted
+		})
+	}()
+
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				snapshot := job.Snapshot()
+				if _, err := json.Marshal(snapshot); err != nil {
+					t.Errorf("marshal snapshot: %v", err)
+					return
+				}
+				// Every snapshot must be internally consistent
+				if len(snapshot.GenresDiscovered) != snapshot.SongsProcessed ||
+					len(snapshot.PlaylistsCreated) != snapshot.SongsProcessed {
+					t.Errorf("torn snapshot: %d songs, %d genres, %d playlists",
+						snapshot.SongsProcessed, len(snapshot.GenresDiscovered), len(snapshot.PlaylistsCreated))
+					return
+				}
+				if snapshot.IsFinished() {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := job.Snapshot().SongsProcessed; got != updates {
+		t.Errorf("expected %d songs processed, got %d", updates, got)
+	}
+}
+
+func TestJobSnapshotIsImmutable(t *testing.T) {
+	job := NewJob(&models.OrganizeJob{ID: "job1"})
+	snapshot := job.Update(func(r *models.OrganizeJob) {
+		r.GenresDiscovered = []string{"rock"}
+	})
+
+	snapshot.GenresDiscovered[0] = "jazz"
+	snapshot.Stage = "tampered"
+
+	if got := job.Snapshot(); got.GenresDiscovered[0] != "rock" || got.Stage != "" {
+		t.Errorf("snapshot shares state with the job: %+v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// MemoryStore is an in-process Store for tests and for running without a
+// database. Jobs are copied in and out so callers never share state.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]*models.OrganizeJob
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*models.OrganizeJob)}
+}
+
+func (s *MemoryStore) Create(job *models.OrganizeJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (s *MemoryStore) Update(job *models.OrganizeJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.UpdatedAt = time.Now()
+	s.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (s *MemoryStore) Get(jobID string) (*models.OrganizeJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return copyJob(job), nil
+}
+
+func (s *MemoryStore) ListByUser(userID string) ([]*models.OrganizeJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []*models.OrganizeJob
+	for _, job := range s.jobs {
+		if job.UserID == userID {
+			jobs = append(jobs, copyJob(job))
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if len(jobs) > historyLimit {
+		jobs = jobs[:historyLimit]
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, job := range s.jobs {
+		if job.CreatedAt.Before(cutoff) {
+			delete(s.jobs, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func copyJob(job *models.OrganizeJob) *models.OrganizeJob {
+	cp := *job
+	cp.GenresDiscovered = append([]string(nil), job.GenresDiscovered...)
+	cp.PlaylistsCreated = append([]models.JobPlaylist(nil), job.PlaylistsCreated...)
+	if job.PlanID != nil {
+		id := *job.PlanID
+		cp.PlanID = &id
+	}
+	if job.CompletedAt != nil {
+		t := *job.CompletedAt
+		cp.CompletedAt = &t
+	}
+	return &cp
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+func TestMemoryStoreCopiesJobs(t *testing.T) {
+	s := NewMemoryStore()
+	job := &models.OrganizeJob{ID: "job1", UserID: "alice", Status: models.JobStatusPending}
+	if err := s.Create(job); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Mutating the caller's copy must not leak into the store until Update
+	job.Status = models.JobStatusProcessing
+	job.GenresDiscovered = append(job.GenresDiscovered, "rock")
+
+	got, err := s.Get("job1")
+	if err != nil || got == nil {
+		t.Fatalf("Get: %v, %v", got, err)
+	}
+	if got.Status != models.JobStatusPending || len(got.GenresDiscovered) != 0 {
+		t.Errorf("store shares state with caller: %+v", got)
+	}
+
+	if err := s.Update(job); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, _ = s.Get("job1")
+	if got.Status != models.JobStatusProcessing || got.UpdatedAt.IsZero() {
+		t.Errorf("update not saved: %+v", got)
+	}
+
+	if missing, err := s.Get("nope"); missing != nil || err != nil {
+		t.Errorf("expected nil for unknown job, got %+v, %v", missing, err)
+	}
+}
+
+func TestMemoryStoreListByUser(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	s.Create(&models.OrganizeJob{ID: "old", UserID: "alice", CreatedAt: now.Add(-time.Hour)})
+	s.Create(&models.OrganizeJob{ID: "new", UserID: "alice", CreatedAt: now})
+	s.Create(&models.OrganizeJob{ID: "other", UserID: "bob", CreatedAt: now})
+
+	jobs, err := s.ListByUser("alice")
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "new" || jobs[1].ID != "old" {
+		t.Errorf("expected alice's jobs newest first, got %+v", jobs)
+	}
+}
+
+func TestMemoryStoreDeleteBefore(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	s.Create(&models.OrganizeJob{ID: "expired", UserID: "alice", CreatedAt: now.Add(-48 * time.Hour)})
+	s.Create(&models.OrganizeJob{ID: "recent", UserID: "alice", CreatedAt: now})
+
+	deleted, err := s.DeleteBefore(now.Add(-24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 job deleted, got %d, %v", deleted, err)
+	}
+	if job, _ := s.Get("expired"); job != nil {
+		t.Error("expected expired job to be gone")
+	}
+	if job, _ := s.Get("recent"); job == nil {
+		t.Error("expected recent job to be kept")
+	}
+}
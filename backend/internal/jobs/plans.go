@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// ErrPlanChanged is returned by PlanStore.Update when the plan was updated
+// since it was read, e.g. by another request or replica
+var ErrPlanChanged = errors.New("plan was changed by another request")
+
+// PlanStore persists organize plans. Get returns nil when the plan doesn't
+// exist.
+type PlanStore interface {
+	Create(plan *models.OrganizePlan) error
+
+	// Update saves plan only if the stored plan is still at plan.Version,
+	// and bumps the version. Otherwise it returns ErrPlanChanged and the
+	// plan has to be read again.
+	Update(plan *models.OrganizePlan) error
+
+	Get(planID string) (*models.OrganizePlan, error)
+
+	// DeleteBefore removes plans created before cutoff, returning how many
+	// were removed
+	DeleteBefore(cutoff time.Time) (int64, error)
+}
+
+// DatabasePlanStore keeps plans in the organize_plans table
+type DatabasePlanStore struct{}
+
+func NewDatabasePlanStore() *DatabasePlanStore {
+	return &DatabasePlanStore{}
+}
+
+func (s *DatabasePlanStore) Create(plan *models.OrganizePlan) error {
+	return database.CreateOrganizePlan(plan)
+}
+
+func (s *DatabasePlanStore) Update(plan *models.OrganizePlan) error {
+	updated, err := database.UpdateOrganizePlan(plan)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrPlanChanged
+	}
+	return nil
+}
+
+func (s *DatabasePlanStore) Get(planID string) (*models.OrganizePlan, error) {
+	return database.GetOrganizePlan(planID)
+}
+
+func (s *DatabasePlanStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	return database.DeleteOrganizePlansBefore(cutoff)
+}
+
+// MemoryPlanStore is an in-process PlanStore for tests and for running
+// without a database. Plans are copied in and out so callers never share
+// state.
+type MemoryPlanStore struct {
+	mu    sync.RWMutex
+	plans map[string]*models.OrganizePlan
+}
+
+func NewMemoryPlanStore() *MemoryPlanStore {
+	return &MemoryPlanStore{plans: make(map[string]*models.OrganizePlan)}
+}
+
+func (s *MemoryPlanStore) Create(plan *models.OrganizePlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.plans[plan.ID] = CopyPlan(plan)
+	return nil
+}
+
+func (s *MemoryPlanStore) Update(plan *models.OrganizePlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.plans[plan.ID]
+	if !ok || stored.Version != plan.Version {
+		return ErrPlanChanged
+	}
+	plan.Version++
+	plan.UpdatedAt = time.Now()
+	s.plans[plan.ID] = CopyPlan(plan)
+	return nil
+}
+
+func (s *MemoryPlanStore) Get(planID string) (*models.OrganizePlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	plan, ok := s.plans[planID]
+	if !ok {
+		return nil, nil
+	}
+	return CopyPlan(plan), nil
+}
+
+func (s *MemoryPlanStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, plan := range s.plans {
+		if plan.CreatedAt.Before(cutoff) {
+			delete(s.plans, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// CopyPlan returns a deep copy of plan
+func CopyPlan(plan *models.OrganizePlan) *models.OrganizePlan {
+	cp := *plan
+	if plan.ApplyJobID != nil {
+		id := *plan.ApplyJobID
+		cp.ApplyJobID = &id
+	}
+	cp.MergedIntoOther = append([]models.MergedGenre(nil), plan.MergedIntoOther...)
+	cp.Playlists = make([]models.PlannedPlaylist, len(plan.Playlists))
+	for i, p := range plan.Playlists {
+		p.Tracks = append([]models.PlannedTrack(nil), p.Tracks...)
+		cp.Playlists[i] = p
+	}
+	return &cp
+}
@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultRetention is how long job records are kept when no retention is
+// configured
+const DefaultRetention = 30 * 24 * time.Hour
+
+// retentionInterval is how often expired jobs are pruned
+const retentionInterval = time.Hour
+
+// pruner is a store whose old records can be deleted
+type pruner interface {
+	DeleteBefore(cutoff time.Time) (int64, error)
+}
+
+// RunRetention deletes jobs and plans older than retention, once straight
+// away and then every hour, until ctx is done. Retention must comfortably
+// exceed how long a job can run so running jobs are never removed.
+func RunRetention(ctx context.Context, jobs Store, plans PlanStore, retention time.Duration) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-retention)
+		prune("organize jobs", jobs, cutoff, retention)
+		prune("organize plans", plans, cutoff, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func prune(what string, store pruner, cutoff time.Time, retention time.Duration) {
+	deleted, err := store.DeleteBefore(cutoff)
+	if err != nil {
+		log.Printf("Failed to prune %s: %v", what, err)
+	} else if deleted > 0 {
+		log.Printf("Pruned %d %s older than %s", deleted, what, retention)
+	}
+}
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Kinds of jobs that modify a user's playlists
+const (
+	KindOrganize = "organize"
+	KindSync     = "sync"
+)
+
+// ActiveJob identifies the job a user has queued or running
+type ActiveJob struct {
+	ID   string `json:"job_id"`
+	Kind string `json:"kind"`
+}
+
+// Scheduler runs jobs that modify a user's playlists on a bounded number of
+// workers. Each user may have only one such job queued or running at a time,
+// so an organize run and a sync never rewrite the same playlists at once.
+// When every worker is busy, jobs wait for one rather than being rejected.
+type Scheduler struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	active map[string]ActiveJob // by user ID
+}
+
+func NewScheduler(workers int) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Scheduler{
+		slots:  make(chan struct{}, workers),
+		active: make(map[string]ActiveJob),
+	}
+}
+
+// Reservation holds a user's job slot until the job has run or is released
+type Reservation struct {
+	s       *Scheduler
+	userID  string
+	job     ActiveJob
+	started atomic.Bool
+}
+
+// Reserve claims the user's job slot for job. If the user already has a job
+// queued or running, the reservation is nil and that job is returned instead.
+func (s *Scheduler) Reserve(userID string, job ActiveJob) (*Reservation, ActiveJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, busy := s.active[userID]; busy {
+		return nil, existing
+	}
+	s.active[userID] = job
+	return &Reservation{s: s, userID: userID, job: job}, job
+}
+
+// Active returns the job the user has queued or running, if any
+func (s *Scheduler) Active(userID string) (ActiveJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.active[userID]
+	return job, ok
+}
+
+// Release gives up the reservation without running anything, e.g. when the
+// job couldn't be created. It is a no-op once the job has been started.
+func (r *Reservation) Release() {
+	if !r.started.Load() {
+		r.release()
+	}
+}
+
+func (r *Reservation) release() {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.active[r.userID] == r.job {
+		delete(r.s.active, r.userID)
+	}
+}
+
+// Start queues run for a free worker and returns a channel that is closed
+// once it has returned and the user's slot is free again. If ctx is done
+// while the job is still queued, run is called straight away with that ctx
+// so the job can record that it was cancelled.
+func (r *Reservation) Start(ctx context.Context, run func(ctx context.Context)) <-chan struct{} {
+	if !r.started.CompareAndSwap(false, true) {
+		panic("jobs: reservation started twice")
+	}
+	return r.s.start(ctx, run, r.release)
+}
+
+// Go queues run for a free worker without claiming a user's slot, for
+// read-only jobs such as dry runs. Otherwise it behaves like Start.
+func (s *Scheduler) Go(ctx context.Context, run func(ctx context.Context)) <-chan struct{} {
+	return s.start(ctx, run, func() {})
+}
+
+func (s *Scheduler) start(ctx context.Context, run func(ctx context.Context), release func()) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer release()
+
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+		}
+
+		run(ctx)
+	}()
+
+	return done
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerOneJobPerUser(t *testing.T) {
+	s := NewScheduler(2)
+
+	first, _ := s.Reserve("alice", ActiveJob{ID: "job1", Kind: KindOrganize})
+	if first == nil {
+		t.Fatal("expected first reservation to succeed")
+	}
+
+	dup, active := s.Reserve("alice", ActiveJob{ID: "job2", Kind: KindSync})
+	if dup != nil || active.ID != "job1" {
+		t.Fatalf("expected duplicate to return job1, got %+v", active)
+	}
+
+	if other, _ := s.Reserve("bob", ActiveJob{ID: "job3", Kind: KindSync}); other == nil {
+		t.Fatal("expected another user's job to be accepted")
+	} else {
+		other.Release()
+	}
+
+	release := make(chan struct{})
+	done := first.Start(context.Background(), func(ctx context.Context) { <-release })
+
+	if _, busy := s.Active("alice"); !busy {
+		t.Error("expected alice to be busy while job1 runs")
+	}
+
+	close(release)
+	<-done
+
+	if _, busy := s.Active("alice"); busy {
+		t.Error("expected alice's slot to be free after job1 finished")
+	}
+}
+
+func TestSchedulerQueuesWhenSaturated(t *testing.T) {
+	s := NewScheduler(1)
+
+	var running, maxRunning atomic.Int32
+	release := make(chan struct{})
+	run := func(ctx context.Context) {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		<-release
+		running.Add(-1)
+	}
+
+	var dones []<-chan struct{}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		r, _ := s.Reserve(user, ActiveJob{ID: user + "-job", Kind: KindOrganize})
+		if r == nil {
+			t.Fatalf("expected %s's job to be queued, not rejected", user)
+		}
+		dones = append(dones, r.Start(context.Background(), run))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := running.Load(); got != 1 {
+		t.Errorf("expected 1 running job with a single worker, got %d", got)
+	}
+
+	close(release)
+	for _, done := range dones {
+		<-done
+	}
+	if got := maxRunning.Load(); got != 1 {
+		t.Errorf("expected at most 1 concurrent job, got %d", got)
+	}
+}
+
+func TestSchedulerRunsCancelledQueuedJobs(t *testing.T) {
+	s := NewScheduler(1)
+
+	blocker, _ := s.Reserve("alice", ActiveJob{ID: "blocker"})
+	release := make(chan struct{})
+	blockerDone := blocker.Start(context.Background(), func(ctx context.Context) { <-release })
+	defer func() {
+		close(release)
+		<-blockerDone
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued, _ := s.Reserve("bob", ActiveJob{ID: "queued"})
+	var sawCancel atomic.Bool
+	done := queued.Start(ctx, func(ctx context.Context) {
+		sawCancel.Store(ctx.Err() != nil)
+	})
+
+	// A queued job that is cancelled doesn't wait for a worker
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cancelled job stayed queued")
+	}
+	if !sawCancel.Load() {
+		t.Error("expected run to see the cancelled context")
+	}
+	if _, busy := s.Active("bob"); busy {
+		t.Error("expected bob's slot to be released")
+	}
+}
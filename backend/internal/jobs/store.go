@@ -0,0 +1,63 @@
+// Package jobs tracks background organize jobs and persists their progress
+// and outcome.
+package jobs
+
+import (
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// historyLimit caps how many jobs ListByUser returns
+const historyLimit = 50
+
+// Store persists organize jobs. Get returns nil when the job doesn't exist.
+// ListByUser returns the user's most recent jobs, newest first.
+type Store interface {
+	Create(job *models.OrganizeJob) error
+	Update(job *models.OrganizeJob) error
+	Get(jobID string) (*models.OrganizeJob, error)
+	ListByUser(userID string) ([]*models.OrganizeJob, error)
+
+	// DeleteBefore removes jobs created before cutoff, returning how many
+	// were removed
+	DeleteBefore(cutoff time.Time) (int64, error)
+}
+
+// DatabaseStore keeps jobs in the organize_jobs table so they survive
+// restarts and are visible to every replica
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Create(job *models.OrganizeJob) error {
+	return database.CreateOrganizeJob(job)
+}
+
+func (s *DatabaseStore) Update(job *models.OrganizeJob) error {
+	return database.UpdateOrganizeJob(job)
+}
+
+func (s *DatabaseStore) Get(jobID string) (*models.OrganizeJob, error) {
+	return database.GetOrganizeJob(jobID)
+}
+
+func (s *DatabaseStore) ListByUser(userID string) ([]*models.OrganizeJob, error) {
+	rows, err := database.ListOrganizeJobs(userID, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*models.OrganizeJob, len(rows))
+	for i := range rows {
+		jobs[i] = &rows[i]
+	}
+	return jobs, nil
+}
+
+func (s *DatabaseStore) DeleteBefore(cutoff time.Time) (int64, error) {
+	return database.DeleteOrganizeJobsBefore(cutoff)
+}
@@ -0,0 +1,301 @@
+// Package library keeps a stored snapshot of each user's liked songs, with
+// their genres and parent genre, and brings it up to date by fetching only
+// what was liked since.
+package library
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/genres"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// FullRefreshInterval is how long a snapshot is updated incrementally before
+// the whole library is fetched and classified again. Unlikes are normally
+// noticed because the library's size no longer adds up, but an unlike and a
+// new like between two refreshes cancel out; the periodic full fetch catches
+// those and picks up artists whose genres changed.
+const FullRefreshInterval = 24 * time.Hour
+
+// Snapshot is the stored copy of a user's liked songs
+type Snapshot struct {
+	UserID string
+
+	// Songs are newest first, with the genres of their artists
+	Songs []spotify.Song
+
+	// ParentGenres records the parent genre the classifier chose for each
+	// song, by track ID
+	ParentGenres map[string]string
+
+	// FullSyncAt is when the whole library was last fetched
+	FullSyncAt time.Time
+	UpdatedAt  time.Time
+}
+
+// Cursor returns when the newest stored song was liked, or the zero time for
+// an empty library
+func (s *Snapshot) Cursor() time.Time {
+	var cursor time.Time
+	for _, song := range s.Songs {
+		if song.AddedAt.After(cursor) {
+			cursor = song.AddedAt
+		}
+	}
+	return cursor
+}
+
+// AddedAfter returns the songs liked after t, newest first
+func (s *Snapshot) AddedAfter(t time.Time) []spotify.Song {
+	var songs []spotify.Song
+	for _, song := range s.Songs {
+		if song.AddedAt.After(t) {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
+
+// ParentGenre returns the parent genre the song was classified into
+func (s *Snapshot) ParentGenre(trackID string) string {
+	return s.ParentGenres[trackID]
+}
+
+// ByParentGenre groups the songs by parent genre, keeping them newest first
+func (s *Snapshot) ByParentGenre() map[string][]spotify.Song {
+	groups := make(map[string][]spotify.Song)
+	for _, song := range s.Songs {
+		genre := s.ParentGenres[song.ID]
+		groups[genre] = append(groups[genre], song)
+	}
+	return groups
+}
+
+// Changes is how a refresh changed the stored library
+type Changes struct {
+	// Added lists songs liked since the previous refresh, newest first
+	Added []spotify.Song
+
+	// Removed lists the IDs of songs that are no longer liked
+	Removed []string
+
+	// Reclassified lists stored songs that now fall into a different parent
+	// genre, because their artists' genres changed
+	Reclassified []spotify.Song
+
+	// Artists lists the artists whose genres were looked up
+	Artists []spotify.Artist
+
+	// Full reports whether the whole library was fetched
+	Full bool
+}
+
+// userLocks serializes refreshes of the same user's snapshot
+var userLocks sync.Map
+
+// Refresh brings the user's stored snapshot up to date and returns it. Only
+// songs liked since the newest stored one are fetched, usually a single page,
+// and only their artists are looked up. The whole library is fetched when
+// there is no snapshot yet, when it is older than FullRefreshInterval, or
+// when songs turn out to have been removed. progress, if set, follows a full
+// fetch.
+func Refresh(ctx context.Context, client *spotify.Client, store Store, userID string, progress func(processed, total int)) (*Snapshot, *Changes, error) {
+	lock, _ := userLocks.LoadOrStore(userID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	previous, err := store.Get(userID)
+	if err != nil {
+		// A broken snapshot is no reason to fail; fetch everything instead
+		log.Printf("library for %s: failed to load snapshot: %v", userID, err)
+		previous = nil
+	}
+
+	var snapshot *Snapshot
+	var changes *Changes
+	if previous != nil && time.Since(previous.FullSyncAt) < FullRefreshInterval {
+		snapshot, changes, err = fetchIncremental(ctx, client, previous)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if snapshot == nil {
+		snapshot, changes, err = fetchFull(ctx, client, userID, previous, progress)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := classify(ctx, client, snapshot, changes); err != nil {
+		return nil, nil, err
+	}
+
+	snapshot.UpdatedAt = time.Now()
+	if err := store.Save(snapshot, changes); err != nil {
+		// The fetched library is still good to use; the next refresh redoes
+		// whatever wasn't saved
+		log.Printf("library for %s: failed to save snapshot: %v", userID, err)
+	}
+
+	return snapshot, changes, nil
+}
+
+// fetchIncremental merges songs liked since the snapshot's cursor into it. It
+// returns a nil snapshot when the result doesn't add up to the library's
+// size, meaning songs were removed and a full fetch is needed.
+func fetchIncremental(ctx context.Context, client *spotify.Client, previous *Snapshot) (*Snapshot, *Changes, error) {
+	recent, total, err := client.FetchLikedSongsSince(ctx, previous.Cursor())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known := make(map[string]spotify.Song, len(previous.Songs))
+	for _, song := range previous.Songs {
+		known[song.ID] = song
+	}
+
+	changes := &Changes{}
+	merged := make([]spotify.Song, 0, len(previous.Songs)+len(recent))
+	seen := make(map[string]bool, len(recent))
+	for _, song := range recent {
+		if seen[song.ID] {
+			continue
+		}
+		seen[song.ID] = true
+
+		// Songs liked again move to the top with a new added_at
+		stored, ok := known[song.ID]
+		if ok && stored.AddedAt.Equal(song.AddedAt) {
+			merged = append(merged, stored)
+			continue
+		}
+		merged = append(merged, song)
+		changes.Added = append(changes.Added, song)
+	}
+	for _, song := range previous.Songs {
+		if !seen[song.ID] {
+			merged = append(merged, song)
+		}
+	}
+
+	if len(merged) != total {
+		log.Printf("library for %s: %d stored songs but %d liked, fetching everything", previous.UserID, len(merged), total)
+		return nil, nil, nil
+	}
+
+	return &Snapshot{
+		UserID:       previous.UserID,
+		Songs:        merged,
+		ParentGenres: copyParentGenres(previous.ParentGenres),
+		FullSyncAt:   previous.FullSyncAt,
+	}, changes, nil
+}
+
+// fetchFull fetches the whole library, working out what changed since the
+// previous snapshot if there is one
+func fetchFull(ctx context.Context, client *spotify.Client, userID string, previous *Snapshot, progress func(processed, total int)) (*Snapshot, *Changes, error) {
+	songs, err := client.FetchAllLikedSongs(ctx, progress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known := make(map[string]time.Time)
+	parentGenres := make(map[string]string)
+	if previous != nil {
+		for _, song := range previous.Songs {
+			known[song.ID] = song.AddedAt
+		}
+		parentGenres = copyParentGenres(previous.ParentGenres)
+	}
+
+	changes := &Changes{Full: true}
+	liked := make(map[string]bool, len(songs))
+	for _, song := range songs {
+		liked[song.ID] = true
+		if addedAt, ok := known[song.ID]; !ok || !addedAt.Equal(song.AddedAt) {
+			changes.Added = append(changes.Added, song)
+		}
+	}
+	for id := range known {
+		if !liked[id] {
+			changes.Removed = append(changes.Removed, id)
+			delete(parentGenres, id)
+		}
+	}
+
+	return &Snapshot{
+		UserID:       userID,
+		Songs:        songs,
+		ParentGenres: parentGenres,
+		FullSyncAt:   time.Now(),
+	}, changes, nil
+}
+
+// classify looks up the genres of the songs that need it and records their
+// parent genre: every song after a full fetch, otherwise only added songs and
+// any that were never classified
+func classify(ctx context.Context, client *spotify.Client, snapshot *Snapshot, changes *Changes) error {
+	added := make(map[string]bool, len(changes.Added))
+	for _, song := range changes.Added {
+		added[song.ID] = true
+	}
+
+	var pending []int
+	var songs []spotify.Song
+	for i, song := range snapshot.Songs {
+		_, classified := snapshot.ParentGenres[song.ID]
+		if changes.Full || added[song.ID] || !classified {
+			pending = append(pending, i)
+			songs = append(songs, song)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	artistGenres, err := client.FetchAllArtistGenres(ctx, songs, nil)
+	if err != nil {
+		return err
+	}
+	spotify.EnrichSongsWithGenres(songs, artistGenres)
+
+	artists := make(map[string]bool)
+	positions := make(map[string]int, len(pending))
+	for j, i := range pending {
+		song := songs[j]
+		snapshot.Songs[i] = song
+		positions[song.ID] = i
+
+		genre := genres.ScoreGenres(song.Genres)
+		previous, classified := snapshot.ParentGenres[song.ID]
+		snapshot.ParentGenres[song.ID] = genre
+		if !added[song.ID] && (!classified || previous != genre) {
+			changes.Reclassified = append(changes.Reclassified, song)
+		}
+
+		for _, a := range song.Artists {
+			if !artists[a.ID] {
+				artists[a.ID] = true
+				changes.Artists = append(changes.Artists, spotify.Artist{ID: a.ID, Name: a.Name, Genres: artistGenres[a.ID]})
+			}
+		}
+	}
+
+	// Keep the added songs' genres in step with the snapshot
+	for i := range changes.Added {
+		changes.Added[i] = snapshot.Songs[positions[changes.Added[i].ID]]
+	}
+
+	return nil
+}
+
+func copyParentGenres(parentGenres map[string]string) map[string]string {
+	cp := make(map[string]string, len(parentGenres))
+	for id, genre := range parentGenres {
+		cp[id] = genre
+	}
+	return cp
+}
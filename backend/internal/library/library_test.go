@@ -0,0 +1,167 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func likedSong(id string, minute int) spotify.Song {
+	return spotify.Song{
+		ID:      id,
+		Name:    "Song " + id,
+		Artists: []spotify.Artist{{ID: "artist", Name: "Artist"}},
+		AddedAt: base.Add(time.Duration(minute) * time.Minute),
+	}
+}
+
+func newFakeLibrary(t *testing.T, size int) (*spotifytest.Server, *spotify.Client) {
+	t.Helper()
+
+	srv := spotifytest.NewServer()
+	t.Cleanup(srv.Close)
+
+	token := srv.AddUser("alice")
+	for i := 0; i < size; i++ {
+		srv.AddLikedSongs("alice", likedSong(fmt.Sprintf("s%d", i), i))
+	}
+
+	return srv, srv.Config().NewClient(token)
+}
+
+func countRequests(srv *spotifytest.Server, want string) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if req == want {
+			n++
+		}
+	}
+	return n
+}
+
+func libraryPages(srv *spotifytest.Server) int {
+	return countRequests(srv, "GET /v1/me/tracks")
+}
+
+func TestRefreshFetchesOnlyNewSongs(t *testing.T) {
+	srv, client := newFakeLibrary(t, 120)
+	store := NewMemoryStore()
+
+	snapshot, changes, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if !changes.Full || len(snapshot.Songs) != 120 || len(changes.Added) != 120 {
+		t.Fatalf("expected a full fetch of 120 songs, got full=%v with %d songs", changes.Full, len(snapshot.Songs))
+	}
+
+	srv.AddLikedSongs("alice", likedSong("new1", 500), likedSong("new2", 501))
+	before := libraryPages(srv)
+
+	snapshot, changes, err = Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+
+	if n := libraryPages(srv) - before; n != 1 {
+		t.Errorf("expected a single page to be fetched, got %d", n)
+	}
+	if changes.Full || len(changes.Added) != 2 || len(changes.Removed) != 0 {
+		t.Errorf("expected 2 added songs from an incremental fetch, got %+v", changes)
+	}
+	if len(snapshot.Songs) != 122 || snapshot.Songs[0].ID != "new2" || snapshot.Songs[2].ID != "s119" {
+		t.Errorf("expected the new songs merged in first, got %d songs starting %s", len(snapshot.Songs), snapshot.Songs[0].ID)
+	}
+
+	stored, _ := store.Get("alice")
+	if len(stored.Songs) != 122 {
+		t.Errorf("expected the merged snapshot to be stored, got %d songs", len(stored.Songs))
+	}
+}
+
+func TestRefreshNoticesRemovedSongs(t *testing.T) {
+	srv, client := newFakeLibrary(t, 10)
+	store := NewMemoryStore()
+
+	if _, _, err := Refresh(context.Background(), client, store, "alice", nil); err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	srv.RemoveLikedSong("alice", "s3")
+
+	snapshot, changes, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+	if !changes.Full || len(changes.Removed) != 1 || changes.Removed[0] != "s3" {
+		t.Errorf("expected a full fetch removing s3, got %+v", changes)
+	}
+	if len(snapshot.Songs) != 9 {
+		t.Errorf("expected 9 songs left, got %d", len(snapshot.Songs))
+	}
+}
+
+func TestRefreshFetchesEverythingOnceSnapshotIsOld(t *testing.T) {
+	_, client := newFakeLibrary(t, 10)
+	store := NewMemoryStore()
+
+	snapshot, _, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	snapshot.FullSyncAt = time.Now().Add(-FullRefreshInterval)
+	store.Save(snapshot, &Changes{})
+
+	_, changes, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+	if !changes.Full || len(changes.Added) != 0 {
+		t.Errorf("expected an unchanged full fetch, got %+v", changes)
+	}
+}
+
+func TestRefreshClassifiesOnlyNewSongs(t *testing.T) {
+	srv, client := newFakeLibrary(t, 60)
+	srv.SetArtistGenres("artist", "indie rock")
+	srv.SetArtistGenres("jazz-artist", "jazz fusion")
+	store := NewMemoryStore()
+
+	snapshot, _, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if got := snapshot.ParentGenre("s0"); got != "Rock" {
+		t.Fatalf("expected s0 to be classified as Rock, got %q", got)
+	}
+
+	jazz := likedSong("j1", 500)
+	jazz.Artists = []spotify.Artist{{ID: "jazz-artist", Name: "Jazz Artist"}}
+	srv.AddLikedSongs("alice", jazz)
+	before := countRequests(srv, "GET /v1/artists")
+
+	snapshot, changes, err := Refresh(context.Background(), client, store, "alice", nil)
+	if err != nil {
+		t.Fatalf("second refresh: %v", err)
+	}
+
+	if n := countRequests(srv, "GET /v1/artists") - before; n != 1 {
+		t.Errorf("expected only the new song's artists to be looked up once, got %d requests", n)
+	}
+	if len(changes.Artists) != 1 || changes.Artists[0].ID != "jazz-artist" {
+		t.Errorf("expected only jazz-artist to be looked up, got %+v", changes.Artists)
+	}
+	groups := snapshot.ByParentGenre()
+	if len(groups["Jazz"]) != 1 || len(groups["Rock"]) != 60 {
+		t.Errorf("expected 1 Jazz and 60 Rock songs, got %d and %d", len(groups["Jazz"]), len(groups["Rock"]))
+	}
+	if len(snapshot.Songs[1].Genres) == 0 {
+		t.Errorf("expected stored songs to keep their genres")
+	}
+}
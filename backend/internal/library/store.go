@@ -0,0 +1,160 @@
+package library
+
+import (
+	"sync"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// Store persists library snapshots. Get returns nil when the user's library
+// was never fetched. Save writes the snapshot's state but only the songs and
+// artists listed in changes, so an incremental refresh stays cheap.
+type Store interface {
+	Get(userID string) (*Snapshot, error)
+	Save(snapshot *Snapshot, changes *Changes) error
+}
+
+// DatabaseStore keeps snapshots in the library_snapshots, library_tracks and
+// library_artists tables
+type DatabaseStore struct{}
+
+func NewDatabaseStore() *DatabaseStore {
+	return &DatabaseStore{}
+}
+
+func (s *DatabaseStore) Get(userID string) (*Snapshot, error) {
+	state, err := database.GetLibrarySnapshot(userID)
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	tracks, err := database.GetLibraryTracks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	artists, err := database.GetLibraryArtists(userID)
+	if err != nil {
+		return nil, err
+	}
+	artistGenres := make(map[string][]string, len(artists))
+	for _, a := range artists {
+		artistGenres[a.ArtistID] = a.Genres
+	}
+
+	snapshot := &Snapshot{
+		UserID:       userID,
+		Songs:        make([]spotify.Song, len(tracks)),
+		ParentGenres: make(map[string]string, len(tracks)),
+		FullSyncAt:   state.FullSyncAt,
+		UpdatedAt:    state.UpdatedAt,
+	}
+	for i, t := range tracks {
+		artists := make([]spotify.Artist, len(t.Artists))
+		for j, a := range t.Artists {
+			artists[j] = spotify.Artist{ID: a.ID, Name: a.Name}
+		}
+		snapshot.Songs[i] = spotify.Song{ID: t.TrackID, Name: t.Name, Artists: artists, AddedAt: t.AddedAt}
+
+		// Tracks stored before they were classified are picked up by the
+		// next refresh
+		if t.ParentGenre != "" {
+			snapshot.ParentGenres[t.TrackID] = t.ParentGenre
+		}
+	}
+	spotify.EnrichSongsWithGenres(snapshot.Songs, artistGenres)
+
+	return snapshot, nil
+}
+
+func (s *DatabaseStore) Save(snapshot *Snapshot, changes *Changes) error {
+	changed := append(append([]spotify.Song(nil), changes.Added...), changes.Reclassified...)
+	tracks := make([]models.LibraryTrack, len(changed))
+	for i, song := range changed {
+		artists := make([]models.LibraryTrackArtist, len(song.Artists))
+		for j, a := range song.Artists {
+			artists[j] = models.LibraryTrackArtist{ID: a.ID, Name: a.Name}
+		}
+		tracks[i] = models.LibraryTrack{
+			UserID:      snapshot.UserID,
+			TrackID:     song.ID,
+			Name:        song.Name,
+			Artists:     artists,
+			AddedAt:     song.AddedAt,
+			ParentGenre: snapshot.ParentGenre(song.ID),
+		}
+	}
+
+	artists := make([]models.LibraryArtist, len(changes.Artists))
+	for i, a := range changes.Artists {
+		genres := a.Genres
+		if genres == nil {
+			genres = []string{}
+		}
+		artists[i] = models.LibraryArtist{UserID: snapshot.UserID, ArtistID: a.ID, Name: a.Name, Genres: genres}
+	}
+
+	// Write the songs before moving the cursor past them
+	if err := database.UpsertLibraryArtists(artists); err != nil {
+		return err
+	}
+	if err := database.UpsertLibraryTracks(tracks); err != nil {
+		return err
+	}
+	if err := database.DeleteLibraryTracks(snapshot.UserID, changes.Removed); err != nil {
+		return err
+	}
+
+	state := &models.LibrarySnapshot{
+		UserID:     snapshot.UserID,
+		TrackCount: len(snapshot.Songs),
+		FullSyncAt: snapshot.FullSyncAt,
+	}
+	if cursor := snapshot.Cursor(); !cursor.IsZero() {
+		state.Cursor = &cursor
+	}
+	return database.SaveLibrarySnapshot(state)
+}
+
+// MemoryStore is an in-process Store for tests and for running without a
+// database. Snapshots are copied in and out so callers never share state.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]*Snapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snapshots: make(map[string]*Snapshot)}
+}
+
+func (s *MemoryStore) Get(userID string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, ok := s.snapshots[userID]
+	if !ok {
+		return nil, nil
+	}
+	return copySnapshot(snapshot), nil
+}
+
+func (s *MemoryStore) Save(snapshot *Snapshot, changes *Changes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots[snapshot.UserID] = copySnapshot(snapshot)
+	return nil
+}
+
+func copySnapshot(snapshot *Snapshot) *Snapshot {
+	cp := *snapshot
+	cp.Songs = make([]spotify.Song, len(snapshot.Songs))
+	for i, song := range snapshot.Songs {
+		song.Genres = append([]string(nil), song.Genres...)
+		cp.Songs[i] = song
+	}
+	cp.ParentGenres = copyParentGenres(snapshot.ParentGenres)
+	return &cp
+}
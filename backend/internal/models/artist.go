@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// ArtistGenres is a cached copy of the genres Spotify lists for an artist,
+// kept in the artist_genres table
+type ArtistGenres struct {
+	ArtistID  string    `json:"artist_id" db:"artist_id"`
+	Genres    []string  `json:"genres" db:"genres"`
+	FetchedAt time.Time `json:"fetched_at" db:"fetched_at"`
+}
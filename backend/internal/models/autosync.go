@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// Auto-sync schedules
+const (
+	AutoSyncOff    = "off"
+	AutoSyncHourly = "hourly"
+	AutoSyncDaily  = "daily"
+	AutoSyncWeekly = "weekly"
+)
+
+// Auto-sync run statuses
+const (
+	AutoSyncRunning   = "running"
+	AutoSyncSucceeded = "succeeded"
+	AutoSyncFailed    = "failed"
+	AutoSyncSkipped   = "skipped"
+)
+
+// AutoSyncInterval returns how often a schedule runs, or zero for off and
+// unknown schedules
+func AutoSyncInterval(schedule string) time.Duration {
+	switch schedule {
+	case AutoSyncHourly:
+		return time.Hour
+	case AutoSyncDaily:
+		return 24 * time.Hour
+	case AutoSyncWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// IsValidAutoSync reports whether value is one of the AutoSync* schedules
+func IsValidAutoSync(value string) bool {
+	return value == AutoSyncOff || AutoSyncInterval(value) > 0
+}
+
+// AutoSyncRun is a row in the auto_sync_runs table
+type AutoSyncRun struct {
+	ID               string     `json:"id,omitempty" db:"id"`
+	UserID           string     `json:"user_id" db:"user_id"`
+	Schedule         string     `json:"schedule" db:"schedule"`
+	ScheduledFor     time.Time  `json:"scheduled_for" db:"scheduled_for"` // start of the period the run is for
+	Status           string     `json:"status" db:"status"`
+	PlaylistsUpdated int        `json:"playlists_updated" db:"playlists_updated"`
+	TracksAdded      int        `json:"tracks_added" db:"tracks_added"`
+	TracksRemoved    int        `json:"tracks_removed" db:"tracks_removed"`
+	ErrorMessage     string     `json:"error_message" db:"error_message"`
+	StartedAt        time.Time  `json:"started_at" db:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at" db:"finished_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Organize job statuses
+const (
+	JobStatusPending    = "pending"
+	JobStatusProcessing = "processing"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+	JobStatusCancelled  = "cancelled"
+)
+
+// OrganizeJob is a row in the organize_jobs table
+type OrganizeJob struct {
+	ID               string        `json:"id" db:"id"`
+	UserID           string        `json:"user_id" db:"user_id"`
+	PlaylistCount    int           `json:"playlist_count" db:"playlist_count"`
+	ReplaceExisting  bool          `json:"replace_existing" db:"replace_existing"`
+	DryRun           bool          `json:"dry_run" db:"dry_run"`
+	Status           string        `json:"status" db:"status"`
+	Stage            string        `json:"stage" db:"stage"`
+	SongsProcessed   int           `json:"songs_processed" db:"songs_processed"`
+	TotalSongs       int           `json:"total_songs" db:"total_songs"`
+	GenresDiscovered []string      `json:"genres_discovered" db:"genres_discovered"`
+	PlaylistsCreated []JobPlaylist `json:"playlists_created" db:"playlists_created"`
+	PlanID           *string       `json:"plan_id" db:"plan_id"` // the plan a dry run produced or an apply job wrote
+	ErrorMessage     string        `json:"error_message" db:"error_message"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at" db:"updated_at"`
+	CompletedAt      *time.Time    `json:"completed_at" db:"completed_at"`
+}
+
+// JobPlaylist is a playlist written by an organize job
+type JobPlaylist struct {
+	Name       string `json:"name"`
+	Genre      string `json:"genre"`
+	SpotifyID  string `json:"spotify_id"`
+	SpotifyURL string `json:"spotify_url"`
+	SongCount  int    `json:"song_count"`
+	Status     string `json:"status,omitempty"` // "skipped" if frozen, "failed" if it couldn't be recorded
+}
+
+// IsFinished reports whether the job has reached a terminal status
+func (j *OrganizeJob) IsFinished() bool {
+	switch j.Status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
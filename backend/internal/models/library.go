@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// LibrarySnapshot describes the stored copy of a user's liked songs, kept in
+// the library_snapshots table with the songs in library_tracks
+type LibrarySnapshot struct {
+	UserID     string `json:"user_id" db:"user_id"`
+	TrackCount int    `json:"track_count" db:"track_count"`
+
+	// Cursor is the added_at of the newest stored track; fetching stops once
+	// it reaches songs added before it
+	Cursor *time.Time `json:"cursor" db:"cursor"`
+
+	// FullSyncAt is when the whole library was last fetched
+	FullSyncAt time.Time `json:"full_sync_at" db:"full_sync_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type LibraryTrack struct {
+	UserID  string               `json:"user_id" db:"user_id"`
+	TrackID string               `json:"track_id" db:"track_id"`
+	Name    string               `json:"name" db:"name"`
+	Artists []LibraryTrackArtist `json:"artists" db:"artists"`
+	AddedAt time.Time            `json:"added_at" db:"added_at"`
+
+	// ParentGenre is what the classifier decided for the track
+	ParentGenre string `json:"parent_genre" db:"parent_genre"`
+}
+
+type LibraryTrackArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LibraryArtist is an artist in a user's library with the genres Spotify
+// listed when their tracks were classified
+type LibraryArtist struct {
+	UserID    string    `json:"user_id" db:"user_id"`
+	ArtistID  string    `json:"artist_id" db:"artist_id"`
+	Name      string    `json:"name" db:"name"`
+	Genres    []string  `json:"genres" db:"genres"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+)
+
+// Organize plan statuses. Only draft and failed plans can be edited or
+// applied.
+const (
+	PlanStatusDraft    = "draft"
+	PlanStatusApplying = "applying"
+	PlanStatusApplied  = "applied"
+	PlanStatusFailed   = "failed"
+)
+
+// What applying a planned playlist does on Spotify
+const (
+	// PlanActionCreate always creates a new playlist
+	PlanActionCreate = "create"
+	// PlanActionReplace replaces the tracks of the target playlist, creating
+	// it if it doesn't exist
+	PlanActionReplace = "replace"
+	// PlanActionAppend adds tracks missing from the target playlist, creating
+	// it if it doesn't exist
+	PlanActionAppend = "append"
+)
+
+// OrganizePlan is what an organize run would write: one playlist per parent
+// genre, in the order they are created. Dry runs persist it in the
+// organize_plans table so it can be reviewed, edited and applied.
+type OrganizePlan struct {
+	ID              string    `json:"id" db:"id"`
+	UserID          string    `json:"user_id" db:"user_id"`
+	Status          string    `json:"status" db:"status"`
+	PlaylistCount   int       `json:"playlist_count" db:"playlist_count"`
+	ReplaceExisting bool      `json:"replace_existing" db:"replace_existing"`
+	ApplyJobID      *string   `json:"apply_job_id" db:"apply_job_id"`
+	Version         int       `json:"version" db:"version"` // bumped by every update
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+
+	Playlists []PlannedPlaylist `json:"playlists" db:"playlists"`
+
+	// MergedIntoOther lists genres that didn't make the playlist count and
+	// were folded into "Other"
+	MergedIntoOther []MergedGenre `json:"merged_into_other" db:"merged_into_other"`
+
+	// UnplacedTracks counts songs that end up in no playlist, which happens
+	// when "Other" itself doesn't make the playlist count or a genre is
+	// dropped from the plan
+	UnplacedTracks int `json:"unplaced_tracks" db:"unplaced_tracks"`
+}
+
+type PlannedPlaylist struct {
+	Genre       string         `json:"genre"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Action      string         `json:"action"`
+	Tracks      []PlannedTrack `json:"tracks"`
+
+	// TargetPlaylistID pins replace/append to a specific playlist; otherwise
+	// the target is looked up by name when the plan is applied
+	TargetPlaylistID string `json:"target_playlist_id,omitempty"`
+}
+
+type PlannedTrack struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Artists []string `json:"artists"`
+}
+
+type MergedGenre struct {
+	Genre     string `json:"genre"`
+	SongCount int    `json:"song_count"`
+}
+
+// IsEditable reports whether the plan may still be edited or applied
+func (p *OrganizePlan) IsEditable() bool {
+	return p.Status == PlanStatusDraft || p.Status == PlanStatusFailed
+}
+
+// TrackIDs returns the IDs of the playlist's tracks in order
+func (p *PlannedPlaylist) TrackIDs() []string {
+	ids := make([]string, len(p.Tracks))
+	for i, t := range p.Tracks {
+		ids[i] = t.ID
+	}
+	return ids
+}
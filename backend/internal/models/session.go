@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Session is a signed-in browser. The browser only holds the session's ID,
+// signed; the user it belongs to and their Spotify tokens stay server-side.
+type Session struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"user_id"`
+	AccessToken    string    `json:"access_token"`
+	RefreshToken   string    `json:"refresh_token"`
+	TokenExpiresAt time.Time `json:"token_expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
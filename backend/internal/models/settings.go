@@ -5,25 +5,59 @@ import (
 	"time"
 )
 
+// What syncing does with tracks in a genre playlist that are no longer liked
+const (
+	// UnlikedKeep leaves them in the playlist
+	UnlikedKeep = "keep"
+	// UnlikedRemove removes them from the playlist
+	UnlikedRemove = "remove"
+	// UnlikedArchive removes them and adds them to the user's "Unliked"
+	// playlist
+	UnlikedArchive = "archive"
+)
+
+// How syncing treats a genre playlist
+const (
+	// SyncPolicyMirror adds and removes tracks so the playlist matches its
+	// genre exactly
+	SyncPolicyMirror = "mirror"
+	// SyncPolicyAppendOnly only adds songs, never removing any
+	SyncPolicyAppendOnly = "append_only"
+	// SyncPolicyFrozen never touches the playlist
+	SyncPolicyFrozen = "frozen"
+)
+
 type UserSettings struct {
 	UserID              string    `json:"user_id" db:"user_id"`
 	NameTemplate        string    `json:"name_template" db:"name_template"`
 	DescriptionTemplate string    `json:"description_template" db:"description_template"`
 	IsPremium           bool      `json:"is_premium" db:"is_premium"`
+	UnlikedTracks       string    `json:"unliked_tracks" db:"unliked_tracks"`
+	AutoSync            string    `json:"auto_sync" db:"auto_sync"`
 	CreatedAt           time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at" db:"updated_at"`
+
+	// UnlikedPlaylistID is the archive playlist created for UnlikedArchive
+	UnlikedPlaylistID *string `json:"unliked_playlist_id" db:"unliked_playlist_id"`
 }
 
 type PlaylistOverride struct {
-	ID                string    `json:"id" db:"id"`
-	UserID            string    `json:"user_id" db:"user_id"`
-	PlaylistSpotifyID string    `json:"playlist_spotify_id" db:"playlist_spotify_id"`
-	CustomName        *string   `json:"custom_name" db:"custom_name"`
-	CustomDescription *string   `json:"custom_description" db:"custom_description"`
-	Genre             string    `json:"genre" db:"genre"`
+	ID                string     `json:"id,omitempty" db:"id"`
+	UserID            string     `json:"user_id" db:"user_id"`
+	PlaylistSpotifyID string     `json:"playlist_spotify_id" db:"playlist_spotify_id"`
+	CustomName        *string    `json:"custom_name" db:"custom_name"`
+	CustomDescription *string    `json:"custom_description" db:"custom_description"`
+	Genre             string     `json:"genre" db:"genre"`
 	LastSyncedAt      *time.Time `json:"last_synced_at" db:"last_synced_at"`
 	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+
+	// SyncPolicy is one of the SyncPolicy* values; empty means mirror
+	SyncPolicy string `json:"sync_policy" db:"sync_policy"`
+
+	// ManagedTracks lists the tracks sync or organize put in the playlist.
+	// Nil means they were never recorded, so every track counts as managed.
+	ManagedTracks []string `json:"managed_tracks" db:"managed_tracks"`
 }
 
 func DefaultSettings(userID string) *UserSettings {
@@ -32,7 +66,35 @@ func DefaultSettings(userID string) *UserSettings {
 		NameTemplate:        "{genre} by Organizer",
 		DescriptionTemplate: "Organized by Spotify Genre Organizer",
 		IsPremium:           false,
+		UnlikedTracks:       UnlikedRemove,
+		AutoSync:            AutoSyncOff,
+	}
+}
+
+// IsValidUnlikedTracks reports whether value is one of the Unliked options
+func IsValidUnlikedTracks(value string) bool {
+	switch value {
+	case UnlikedKeep, UnlikedRemove, UnlikedArchive:
+		return true
+	}
+	return false
+}
+
+// IsValidSyncPolicy reports whether value is one of the SyncPolicy* values
+func IsValidSyncPolicy(value string) bool {
+	switch value {
+	case SyncPolicyMirror, SyncPolicyAppendOnly, SyncPolicyFrozen:
+		return true
+	}
+	return false
+}
+
+// Policy returns how syncing treats the playlist
+func (o *PlaylistOverride) Policy() string {
+	if o.SyncPolicy == "" {
+		return SyncPolicyMirror
 	}
+	return o.SyncPolicy
 }
 
 // BuildPlaylistName replaces tokens in the template with actual values
@@ -1,8 +1,12 @@
 package organizer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/spotify-genre-organizer/backend/internal/database"
@@ -11,6 +15,11 @@ import (
 	"github.com/spotify-genre-organizer/backend/internal/spotify"
 )
 
+// playlistWriteTimeout bounds writing one playlist. Cancellation doesn't
+// interrupt a playlist halfway, so without it a hung Spotify call would hold
+// the user's job forever.
+const playlistWriteTimeout = 10 * time.Minute
+
 type OrganizeResult struct {
 	Playlists []PlaylistResult `json:"playlists"`
 }
@@ -21,18 +30,50 @@ type PlaylistResult struct {
 	SpotifyID  string `json:"spotify_id"`
 	SpotifyURL string `json:"spotify_url"`
 	SongCount  int    `json:"song_count"`
+
+	// Status is empty for a playlist that was written, or one of
+	// PlaylistSkipped and PlaylistFailed
+	Status string `json:"status,omitempty"`
 }
 
+const (
+	// PlaylistSkipped marks a playlist that was left alone because it is
+	// frozen
+	PlaylistSkipped = "skipped"
+	// PlaylistFailed marks a playlist that was written but whose record of
+	// which tracks organize added couldn't be saved
+	PlaylistFailed = "failed"
+)
+
+// errFrozen is returned by writePlaylist for a target the user froze
+var errFrozen = errors.New("playlist is frozen")
+
 type ProgressCallback func(stage string, processed, total int)
 
+// PlaylistCallback is called as soon as each playlist has been written
+type PlaylistCallback func(playlist PlaylistResult)
+
+// OrganizeSongs groups songs by parent genre and writes one playlist per
+// genre. Cancelling ctx stops the run between playlists; the playlist being
+// written when that happens is finished first. On error the returned result
+// still lists the playlists completed so far.
 func OrganizeSongs(
-	accessToken string,
+	ctx context.Context,
+	client *spotify.Client,
 	userID string,
 	songs []spotify.Song,
 	playlistCount int,
 	replaceExisting bool,
 	progress ProgressCallback,
+	onPlaylist PlaylistCallback,
 ) (*OrganizeResult, error) {
+	plan := PlanSongs(userID, songs, playlistCount, replaceExisting)
+	return ApplyPlan(ctx, client, userID, plan, progress, onPlaylist)
+}
+
+// PlanSongs works out which playlists OrganizeSongs would write for the
+// user's songs, using their naming templates. It makes no Spotify calls.
+func PlanSongs(userID string, songs []spotify.Song, playlistCount int, replaceExisting bool) *models.OrganizePlan {
 	// Fetch user settings
 	settings, err := database.GetUserSettings(userID)
 	if err != nil {
@@ -40,6 +81,15 @@ func OrganizeSongs(
 		settings = models.DefaultSettings(userID)
 	}
 
+	plan := BuildPlan(songs, playlistCount, replaceExisting, settings)
+	plan.UserID = userID
+	return plan
+}
+
+// BuildPlan groups songs by parent genre, keeping the playlistCount largest
+// genres and folding the rest into "Other". With replaceExisting, playlists
+// of the same name are reused instead of creating new ones.
+func BuildPlan(songs []spotify.Song, playlistCount int, replaceExisting bool, settings *models.UserSettings) *models.OrganizePlan {
 	// Group songs by parent genre
 	genreGroups := make(map[string][]spotify.Song)
 	for _, song := range songs {
@@ -66,92 +116,204 @@ func OrganizeSongs(
 		return sortedGenres[i].count > sortedGenres[j].count
 	})
 
+	plan := &models.OrganizePlan{
+		Status:          models.PlanStatusDraft,
+		PlaylistCount:   playlistCount,
+		ReplaceExisting: replaceExisting,
+		Playlists:       []models.PlannedPlaylist{},
+		MergedIntoOther: []models.MergedGenre{},
+	}
+
+	action := models.PlanActionCreate
+	if replaceExisting {
+		action = models.PlanActionReplace
+	}
+
 	// Limit to requested playlist count
 	if len(sortedGenres) > playlistCount {
-		// Merge smaller genres into "Other"
-		for i := playlistCount; i < len(sortedGenres); i++ {
-			genreGroups["Other"] = append(genreGroups["Other"], genreGroups[sortedGenres[i].genre]...)
-			delete(genreGroups, sortedGenres[i].genre)
+		// Merge smaller genres into "Other". If "Other" itself didn't make
+		// the cut, those songs aren't placed in any playlist.
+		otherKept := false
+		for _, gc := range sortedGenres[:playlistCount] {
+			otherKept = otherKept || gc.genre == "Other"
+		}
+		for _, gc := range sortedGenres[playlistCount:] {
+			if gc.genre != "Other" {
+				plan.MergedIntoOther = append(plan.MergedIntoOther, models.MergedGenre{Genre: gc.genre, SongCount: gc.count})
+			}
+			if otherKept {
+				genreGroups["Other"] = append(genreGroups["Other"], genreGroups[gc.genre]...)
+			} else {
+				plan.UnplacedTracks += gc.count
+			}
+			delete(genreGroups, gc.genre)
 		}
 		sortedGenres = sortedGenres[:playlistCount]
 	}
 
+	for _, gc := range sortedGenres {
+		songs := genreGroups[gc.genre]
+		tracks := make([]models.PlannedTrack, len(songs))
+		for i, song := range songs {
+			artists := make([]string, len(song.Artists))
+			for j, a := range song.Artists {
+				artists[j] = a.Name
+			}
+			tracks[i] = models.PlannedTrack{ID: song.ID, Name: song.Name, Artists: artists}
+		}
+
+		plan.Playlists = append(plan.Playlists, models.PlannedPlaylist{
+			Genre:       gc.genre,
+			Name:        settings.BuildPlaylistName(gc.genre),
+			Description: settings.BuildDescription(gc.genre),
+			Action:      action,
+			Tracks:      tracks,
+		})
+	}
+
+	return plan
+}
+
+// ApplyPlan writes the plan's playlists to Spotify according to each
+// playlist's action. Cancellation behaves as described on OrganizeSongs.
+func ApplyPlan(
+	ctx context.Context,
+	client *spotify.Client,
+	userID string,
+	plan *models.OrganizePlan,
+	progress ProgressCallback,
+	onPlaylist PlaylistCallback,
+) (*OrganizeResult, error) {
+	targets := loadPlaylistTargets(client, userID)
+
 	// Create playlists
 	var results []PlaylistResult
-	total := len(sortedGenres)
+	var failed []string
+	total := len(plan.Playlists)
+	partial := func(err error) (*OrganizeResult, error) {
+		return &OrganizeResult{Playlists: results}, err
+	}
+
+	for i, planned := range plan.Playlists {
+		if err := ctx.Err(); err != nil {
+			return partial(err)
+		}
+
+		// Don't let cancellation interrupt a playlist halfway through; the
+		// calls below only stop for cancellation once this genre is written,
+		// or if writing it takes longer than playlistWriteTimeout
+		playlistCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), playlistWriteTimeout)
 
-	for i, gc := range sortedGenres {
 		if progress != nil {
 			progress("creating", i+1, total)
 		}
 
-		// Create or Update Playlist
-		playlistName := settings.BuildPlaylistName(gc.genre)
-		playlistDescription := settings.BuildDescription(gc.genre)
-		songs := genreGroups[gc.genre]
+		playlist, added, replaced, err := writePlaylist(playlistCtx, client, userID, targets, &planned)
+		cancel()
+		status := ""
+		switch {
+		case errors.Is(err, errFrozen):
+			status = PlaylistSkipped
+		case err != nil:
+			return partial(err)
+		default:
+			// Record which playlist holds the genre and which tracks organize
+			// put there, with last_synced_at for sync tracking
+			if err := targets.record(playlist.ID, planned.Genre, added, replaced); err != nil {
+				log.Printf("organize for %s: failed to save playlist %s: %v", userID, playlist.ID, err)
+				status = PlaylistFailed
+				failed = append(failed, planned.Genre)
+			}
+		}
 
-		var playlist *spotify.Playlist
-		var err error
+		created := PlaylistResult{
+			Name:       planned.Name,
+			Genre:      planned.Genre,
+			SpotifyID:  playlist.ID,
+			SpotifyURL: playlist.ExternalURL,
+			SongCount:  len(planned.Tracks),
+			Status:     status,
+		}
+		results = append(results, created)
+		if onPlaylist != nil {
+			onPlaylist(created)
+		}
+	}
 
-		if replaceExisting {
-			// Check for existing playlist
-			playlist, err = spotify.FindExistingPlaylist(accessToken, playlistName)
-			if err != nil {
-				return nil, err
-			}
+	if len(failed) > 0 {
+		return partial(fmt.Errorf("failed to save %s", strings.Join(failed, ", ")))
+	}
+	return &OrganizeResult{Playlists: results}, nil
+}
 
-			if playlist != nil {
-				// Clear existing tracks
-				if err := spotify.ClearPlaylist(accessToken, playlist.ID); err != nil {
-					return nil, err
-				}
-			}
+// writePlaylist creates or updates the Spotify playlist for one planned genre.
+// It returns the tracks it added, and whether they are all the playlist holds
+// because it was created or replaced. The target's sync policy is honoured:
+// a frozen playlist is returned untouched with errFrozen, and an append-only
+// one is only ever added to.
+func writePlaylist(ctx context.Context, client *spotify.Client, userID string, targets *playlistTargets, planned *models.PlannedPlaylist) (*spotify.Playlist, []string, bool, error) {
+	var playlist *spotify.Playlist
+	var err error
+	action := planned.Action
+
+	if action == models.PlanActionReplace || action == models.PlanActionAppend {
+		playlist, err = targets.find(ctx, planned)
+		if err != nil {
+			return nil, nil, false, err
 		}
+	}
 
-		if playlist == nil {
-			// Create new playlist
-			playlist, err = spotify.CreatePlaylist(
-				accessToken,
-				userID,
-				playlistName,
-				playlistDescription,
-			)
-			if err != nil {
-				return nil, err
-			}
+	if playlist != nil {
+		switch targets.policy(playlist.ID) {
+		case models.SyncPolicyFrozen:
+			return playlist, nil, false, errFrozen
+		case models.SyncPolicyAppendOnly:
+			action = models.PlanActionAppend
 		}
+	}
+
+	trackIDs := planned.TrackIDs()
+	replaced := true
 
-		// Add tracks
-		trackIDs := make([]string, len(songs))
-		for i, s := range songs {
-			trackIDs[i] = s.ID
+	switch {
+	case playlist == nil:
+		// Create new playlist
+		playlist, err = client.CreatePlaylist(ctx, userID, planned.Name, planned.Description)
+		if err != nil {
+			return nil, nil, false, err
 		}
 
-		if err := spotify.AddTracksToPlaylist(accessToken, playlist.ID, trackIDs); err != nil {
-			return nil, err
+	case action == models.PlanActionReplace:
+		// Replace existing tracks
+		if _, err := client.ReplacePlaylistTracks(ctx, playlist.ID, trackIDs); err != nil {
+			return nil, nil, false, err
 		}
+		return playlist, trackIDs, true, nil
 
-		// Save playlist override with last_synced_at for sync tracking
-		now := time.Now()
-		override := &models.PlaylistOverride{
-			UserID:            userID,
-			PlaylistSpotifyID: playlist.ID,
-			Genre:             gc.genre,
-			LastSyncedAt:      &now,
+	case action == models.PlanActionAppend:
+		// Only add what isn't there yet
+		replaced = false
+		existing, err := client.GetPlaylistTrackIDs(ctx, playlist.ID)
+		if err != nil {
+			return nil, nil, false, err
 		}
-		if err := database.SavePlaylistOverride(override); err != nil {
-			log.Printf("Failed to save playlist override for %s: %v", playlist.ID, err)
-			// Don't fail the whole operation for this
+		present := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			present[id] = true
 		}
+		var missing []string
+		for _, id := range trackIDs {
+			if !present[id] {
+				missing = append(missing, id)
+			}
+		}
+		trackIDs = missing
+	}
 
-		results = append(results, PlaylistResult{
-			Name:       playlistName,
-			Genre:      gc.genre,
-			SpotifyID:  playlist.ID,
-			SpotifyURL: playlist.ExternalURL,
-			SongCount:  len(songs),
-		})
+	// Add tracks
+	if _, err := client.AddTracksToPlaylist(ctx, playlist.ID, trackIDs); err != nil {
+		return nil, nil, false, err
 	}
 
-	return &OrganizeResult{Playlists: results}, nil
+	return playlist, trackIDs, replaced, nil
 }
@@ -0,0 +1,523 @@
+package organizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+func song(id, artistID string) spotify.Song {
+	return spotify.Song{
+		ID:      id,
+		Name:    "Song " + id,
+		Artists: []spotify.Artist{{ID: artistID, Name: "Artist " + artistID}},
+	}
+}
+
+func useMemoryOverrides(t *testing.T) *MemoryOverrideStore {
+	m := NewMemoryOverrideStore()
+	prev := overrides
+	overrides = m
+	t.Cleanup(func() { overrides = prev })
+	return m
+}
+
+// failingOverrides can't save, as when the database is unavailable
+type failingOverrides struct {
+	*MemoryOverrideStore
+}
+
+func (failingOverrides) SavePlaylistOverride(override *models.PlaylistOverride) error {
+	return errors.New("database unavailable")
+}
+
+func newFakeLibrary(t *testing.T) (*spotifytest.Server, *spotify.Client) {
+	t.Helper()
+	useMemoryOverrides(t)
+
+	srv := spotifytest.NewServer()
+	t.Cleanup(srv.Close)
+
+	token := srv.AddUser("alice")
+	srv.SetArtistGenres("rock-artist", "indie rock", "garage rock")
+	srv.SetArtistGenres("jazz-artist", "jazz fusion")
+	srv.SetArtistGenres("pop-artist", "dance pop")
+	srv.AddLikedSongs("alice",
+		song("r1", "rock-artist"), song("r2", "rock-artist"), song("r3", "rock-artist"),
+		song("j1", "jazz-artist"), song("j2", "jazz-artist"),
+		song("p1", "pop-artist"),
+	)
+
+	return srv, srv.Config().NewClient(token)
+}
+
+func fetchEnriched(t *testing.T, client *spotify.Client) []spotify.Song {
+	t.Helper()
+
+	songs, err := client.FetchAllLikedSongs(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("FetchAllLikedSongs: %v", err)
+	}
+	artistGenres, err := client.FetchAllArtistGenres(context.Background(), songs, nil)
+	if err != nil {
+		t.Fatalf("FetchAllArtistGenres: %v", err)
+	}
+	spotify.EnrichSongsWithGenres(songs, artistGenres)
+	return songs
+}
+
+func TestOrganizeSongsCreatesGenrePlaylists(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	if len(songs) != 6 {
+		t.Fatalf("expected 6 liked songs, got %d", len(songs))
+	}
+
+	result, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, false, nil, nil)
+	if err != nil {
+		t.Fatalf("OrganizeSongs: %v", err)
+	}
+
+	if len(result.Playlists) != 2 {
+		t.Fatalf("expected 2 playlists, got %d", len(result.Playlists))
+	}
+
+	want := map[string]int{"Rock": 3, "Jazz": 2}
+	for _, p := range result.Playlists {
+		if want[p.Genre] != p.SongCount {
+			t.Errorf("playlist %q: expected %d songs, got %d", p.Genre, want[p.Genre], p.SongCount)
+		}
+
+		created, ok := srv.Playlist(p.SpotifyID)
+		if !ok {
+			t.Fatalf("playlist %s not created on Spotify", p.SpotifyID)
+		}
+		if created.Name != p.Genre+" by Organizer" {
+			t.Errorf("unexpected playlist name %q", created.Name)
+		}
+		if len(created.Tracks) != p.SongCount {
+			t.Errorf("playlist %q: expected %d tracks on Spotify, got %d", p.Genre, p.SongCount, len(created.Tracks))
+		}
+	}
+}
+
+func TestOrganizeSongsReplaceExistingReusesPlaylists(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	if _, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, true, nil, nil); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if _, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, true, nil, nil); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	playlists := srv.Playlists("alice")
+	if len(playlists) != 2 {
+		t.Fatalf("expected 2 playlists after re-running, got %d", len(playlists))
+	}
+	for _, p := range playlists {
+		if p.Name == "Rock by Organizer" && len(p.Tracks) != 3 {
+			t.Errorf("expected Rock playlist to hold 3 tracks, got %d", len(p.Tracks))
+		}
+	}
+}
+
+func TestOrganizeSongsSurvivesRateLimiting(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	srv.FailNext(2, http.StatusTooManyRequests)
+
+	result, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, false, nil, nil)
+	if err != nil {
+		t.Fatalf("OrganizeSongs: %v", err)
+	}
+	if len(result.Playlists) != 2 {
+		t.Errorf("expected 2 playlists, got %d", len(result.Playlists))
+	}
+	if got := client.Stats().RateLimited; got != 2 {
+		t.Errorf("expected 2 rate-limited responses, got %d", got)
+	}
+}
+
+func TestOrganizeSongsStopsWhenCancelled(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := OrganizeSongs(ctx, client, "alice", songs, 2, false, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if playlists := srv.Playlists("alice"); len(playlists) != 0 {
+		t.Errorf("expected no playlists to be created, got %d", len(playlists))
+	}
+}
+
+func TestOrganizeSongsCancelledMidRunKeepsFinishedPlaylists(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel while the first playlist is being written
+	result, err := OrganizeSongs(ctx, client, "alice", songs, 2, false, func(stage string, processed, total int) {
+		if processed == 1 {
+			cancel()
+		}
+	}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result == nil || len(result.Playlists) != 1 {
+		t.Fatalf("expected the in-progress playlist to be finished and reported, got %+v", result)
+	}
+
+	created, ok := srv.Playlist(result.Playlists[0].SpotifyID)
+	if !ok || len(created.Tracks) != result.Playlists[0].SongCount {
+		t.Errorf("expected finished playlist with %d tracks, got %+v", result.Playlists[0].SongCount, created)
+	}
+	if n := len(srv.Playlists("alice")); n != 1 {
+		t.Errorf("expected only 1 playlist on Spotify, got %d", n)
+	}
+}
+
+func TestBuildPlanMergesSmallGenresIntoOther(t *testing.T) {
+	var songs []spotify.Song
+	add := func(n int, genre string) {
+		for i := 0; i < n; i++ {
+			s := song(fmt.Sprintf("%s%d", genre, i), "artist")
+			if genre != "" {
+				s.Genres = []string{genre}
+			}
+			songs = append(songs, s)
+		}
+	}
+	add(5, "indie rock")
+	add(4, "") // no genres, lands in "Other"
+	add(2, "jazz fusion")
+	add(1, "dance pop")
+
+	plan := BuildPlan(songs, 2, false, models.DefaultSettings("alice"))
+
+	if len(plan.Playlists) != 2 {
+		t.Fatalf("expected 2 playlists, got %d", len(plan.Playlists))
+	}
+	other := plan.Playlists[1]
+	if other.Genre != "Other" || len(other.Tracks) != 7 {
+		t.Errorf("expected Other to absorb jazz and pop (7 tracks), got %s with %d", other.Genre, len(other.Tracks))
+	}
+	if other.Name != "Other by Organizer" {
+		t.Errorf("unexpected playlist name %q", other.Name)
+	}
+	if len(plan.MergedIntoOther) != 2 || plan.UnplacedTracks != 0 {
+		t.Errorf("expected 2 merged genres and nothing unplaced, got %+v / %d", plan.MergedIntoOther, plan.UnplacedTracks)
+	}
+}
+
+func TestApplyPlanAppendAddsOnlyMissingTracks(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	existing := srv.AddPlaylist("alice", "My Rock", "r1", "x1")
+
+	plan := &models.OrganizePlan{
+		Playlists: []models.PlannedPlaylist{{
+			Genre:            "Rock",
+			Name:             "Rock by Organizer",
+			Action:           models.PlanActionAppend,
+			TargetPlaylistID: existing,
+			Tracks:           []models.PlannedTrack{{ID: "r1"}, {ID: "r2"}},
+		}},
+	}
+
+	result, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil)
+	if err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	if len(result.Playlists) != 1 || result.Playlists[0].SpotifyID != existing {
+		t.Fatalf("expected the pinned playlist to be used, got %+v", result.Playlists)
+	}
+
+	p, _ := srv.Playlist(existing)
+	if got := p.TrackIDs(); len(got) != 3 || got[2] != "r2" {
+		t.Errorf("expected r2 appended after the existing tracks, got %v", got)
+	}
+	if len(srv.Playlists("alice")) != 1 {
+		t.Errorf("expected no new playlist, got %d", len(srv.Playlists("alice")))
+	}
+}
+
+func TestOrganizeSongsReusesStoredPlaylistAfterRename(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	first, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, true, nil, nil)
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	rockID := first.Playlists[0].SpotifyID
+	if err := client.UpdatePlaylistDetails(context.Background(), rockID, "My guitars", ""); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	second, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, true, nil, nil)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	if second.Playlists[0].SpotifyID != rockID {
+		t.Errorf("expected the renamed playlist %s to be reused, got %s", rockID, second.Playlists[0].SpotifyID)
+	}
+	if n := len(srv.Playlists("alice")); n != 2 {
+		t.Errorf("expected 2 playlists after re-running, got %d", n)
+	}
+}
+
+func TestOrganizeSongsDoesNotReviveDeletedPlaylist(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	stored := useMemoryOverrides(t)
+	songs := fetchEnriched(t, client)
+
+	first, err := OrganizeSongs(context.Background(), client, "alice", songs, 1, true, nil, nil)
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	deletedID := first.Playlists[0].SpotifyID
+	if err := client.UnfollowPlaylist(context.Background(), deletedID); err != nil {
+		t.Fatalf("unfollow: %v", err)
+	}
+
+	second, err := OrganizeSongs(context.Background(), client, "alice", songs, 1, true, nil, nil)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	if second.Playlists[0].SpotifyID == deletedID {
+		t.Fatalf("expected a new playlist instead of the deleted %s", deletedID)
+	}
+	if n := len(srv.Playlists("alice")); n != 1 {
+		t.Errorf("expected 1 playlist in the library, got %d", n)
+	}
+	if _, ok := stored.overrides[deletedID]; ok {
+		t.Errorf("expected the deleted playlist to be forgotten")
+	}
+}
+
+func TestOrganizeSongsAdoptsOnlyOwnedPlaylistsAcrossPages(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	songs := fetchEnriched(t, client)
+
+	// The library lists newest first, so the Rock playlist ends up on the
+	// second page
+	rockID := srv.AddPlaylist("alice", "Rock by Organizer")
+	for i := 0; i < 60; i++ {
+		srv.AddPlaylist("alice", fmt.Sprintf("Mixtape %d", i))
+	}
+	srv.AddUser("bob")
+	bobsJazz := srv.AddPlaylist("bob", "Jazz by Organizer")
+	srv.FollowPlaylist("alice", bobsJazz)
+
+	result, err := OrganizeSongs(context.Background(), client, "alice", songs, 2, true, nil, nil)
+	if err != nil {
+		t.Fatalf("OrganizeSongs: %v", err)
+	}
+
+	for _, p := range result.Playlists {
+		switch p.Genre {
+		case "Rock":
+			if p.SpotifyID != rockID {
+				t.Errorf("expected Rock to adopt %s from the second page, got %s", rockID, p.SpotifyID)
+			}
+		case "Jazz":
+			if p.SpotifyID == bobsJazz {
+				t.Errorf("expected bob's Jazz playlist to be left alone")
+			}
+		}
+	}
+
+	listings := 0
+	for _, req := range srv.Requests() {
+		if req == "GET /v1/me/playlists" {
+			listings++
+		}
+	}
+	if listings != 2 {
+		t.Errorf("expected the library to be listed once (2 pages), got %d requests", listings)
+	}
+}
+
+func TestApplyPlanReplacesLargePlaylistCompletely(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+
+	var old []string
+	for i := 0; i < 150; i++ {
+		old = append(old, fmt.Sprintf("old%d", i))
+	}
+	existing := srv.AddPlaylist("alice", "Rock by Organizer", old...)
+
+	planned := models.PlannedPlaylist{Genre: "Rock", Name: "Rock by Organizer", Action: models.PlanActionReplace}
+	for i := 0; i < 120; i++ {
+		planned.Tracks = append(planned.Tracks, models.PlannedTrack{ID: fmt.Sprintf("new%d", i)})
+	}
+
+	plan := &models.OrganizePlan{Playlists: []models.PlannedPlaylist{planned}}
+	if _, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+
+	p, _ := srv.Playlist(existing)
+	got := p.TrackIDs()
+	if len(got) != 120 || got[0] != "new0" || got[119] != "new119" {
+		t.Errorf("expected exactly the 120 planned tracks in order, got %d starting %v", len(got), got[:1])
+	}
+}
+
+func TestApplyPlanRecordsManagedTracks(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	stored := overrides.(*MemoryOverrideStore)
+
+	// x1 was added by the user after organize last wrote r1
+	existing := srv.AddPlaylist("alice", "Rock by Organizer", "r1", "x1")
+	stored.SavePlaylistOverride(&models.PlaylistOverride{
+		UserID:            "alice",
+		PlaylistSpotifyID: existing,
+		Genre:             "Rock",
+		ManagedTracks:     []string{"r1"},
+	})
+
+	plan := &models.OrganizePlan{
+		Playlists: []models.PlannedPlaylist{
+			{Genre: "Rock", Name: "Rock by Organizer", Action: models.PlanActionAppend, Tracks: []models.PlannedTrack{{ID: "r1"}, {ID: "r2"}}},
+			{Genre: "Jazz", Name: "Jazz by Organizer", Action: models.PlanActionReplace, Tracks: []models.PlannedTrack{{ID: "j1"}}},
+		},
+	}
+
+	result, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil)
+	if err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+
+	if got := stored.overrides[existing].ManagedTracks; !reflect.DeepEqual(got, []string{"r1", "r2"}) {
+		t.Errorf("expected appended r2 to be managed alongside r1, got %v", got)
+	}
+	jazz := result.Playlists[1].SpotifyID
+	if got := stored.overrides[jazz].ManagedTracks; !reflect.DeepEqual(got, []string{"j1"}) {
+		t.Errorf("expected the new playlist's tracks to be managed, got %v", got)
+	}
+}
+
+func TestApplyPlanSkipsFrozenPlaylists(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	stored := overrides.(*MemoryOverrideStore)
+
+	frozen := srv.AddPlaylist("alice", "My Rock", "r1", "x1")
+	stored.SavePlaylistOverride(&models.PlaylistOverride{
+		UserID:            "alice",
+		PlaylistSpotifyID: frozen,
+		Genre:             "Rock",
+		SyncPolicy:        models.SyncPolicyFrozen,
+	})
+
+	plan := &models.OrganizePlan{
+		Playlists: []models.PlannedPlaylist{
+			{Genre: "Rock", Name: "Rock by Organizer", Action: models.PlanActionReplace, Tracks: []models.PlannedTrack{{ID: "r2"}}},
+			{Genre: "Jazz", Name: "Jazz by Organizer", Action: models.PlanActionReplace, Tracks: []models.PlannedTrack{{ID: "j1"}}},
+		},
+	}
+
+	result, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil)
+	if err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	if len(result.Playlists) != 2 || result.Playlists[0].SpotifyID != frozen || result.Playlists[0].Status != PlaylistSkipped {
+		t.Fatalf("expected the frozen playlist to be reported as skipped, got %+v", result.Playlists)
+	}
+	if result.Playlists[1].Status != "" {
+		t.Errorf("expected the jazz playlist to be written, got %+v", result.Playlists[1])
+	}
+
+	p, _ := srv.Playlist(frozen)
+	if got := p.TrackIDs(); !reflect.DeepEqual(got, []string{"r1", "x1"}) {
+		t.Errorf("expected the frozen playlist untouched, got %v", got)
+	}
+	if o := stored.overrides[frozen]; o.LastSyncedAt != nil || o.ManagedTracks != nil {
+		t.Errorf("expected the frozen playlist's record untouched, got %+v", o)
+	}
+}
+
+func TestApplyPlanOnlyAddsToAppendOnlyPlaylists(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	stored := overrides.(*MemoryOverrideStore)
+
+	existing := srv.AddPlaylist("alice", "My Rock", "r1", "x1")
+	stored.SavePlaylistOverride(&models.PlaylistOverride{
+		UserID:            "alice",
+		PlaylistSpotifyID: existing,
+		Genre:             "Rock",
+		SyncPolicy:        models.SyncPolicyAppendOnly,
+		ManagedTracks:     []string{"r1"},
+	})
+
+	plan := &models.OrganizePlan{
+		Playlists: []models.PlannedPlaylist{{
+			Genre:  "Rock",
+			Name:   "Rock by Organizer",
+			Action: models.PlanActionReplace,
+			Tracks: []models.PlannedTrack{{ID: "r2"}},
+		}},
+	}
+
+	if _, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+
+	p, _ := srv.Playlist(existing)
+	if got := p.TrackIDs(); !reflect.DeepEqual(got, []string{"r1", "x1", "r2"}) {
+		t.Errorf("expected r2 added without removing anything, got %v", got)
+	}
+	if got := stored.overrides[existing].ManagedTracks; !reflect.DeepEqual(got, []string{"r1", "r2"}) {
+		t.Errorf("expected r2 to be managed alongside r1, got %v", got)
+	}
+}
+
+func TestApplyPlanFailsPlaylistsItCannotRecord(t *testing.T) {
+	srv, client := newFakeLibrary(t)
+	overrides = failingOverrides{overrides.(*MemoryOverrideStore)}
+
+	plan := &models.OrganizePlan{
+		Playlists: []models.PlannedPlaylist{
+			{Genre: "Rock", Name: "Rock by Organizer", Action: models.PlanActionCreate, Tracks: []models.PlannedTrack{{ID: "r1"}}},
+			{Genre: "Jazz", Name: "Jazz by Organizer", Action: models.PlanActionCreate, Tracks: []models.PlannedTrack{{ID: "j1"}}},
+		},
+	}
+
+	result, err := ApplyPlan(context.Background(), client, "alice", plan, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error when which tracks organize added can't be saved")
+	}
+
+	// Both playlists were written, and are reported so applying again
+	// updates them rather than creating duplicates
+	if result == nil || len(result.Playlists) != 2 {
+		t.Fatalf("expected both playlists in the result, got %+v", result)
+	}
+	for _, p := range result.Playlists {
+		if p.Status != PlaylistFailed || p.SpotifyID == "" {
+			t.Errorf("expected %s to be reported as failed, got %+v", p.Genre, p)
+		}
+	}
+	if len(srv.Playlists("alice")) != 2 {
+		t.Errorf("expected both playlists on Spotify, got %d", len(srv.Playlists("alice")))
+	}
+}
@@ -0,0 +1,77 @@
+package organizer
+
+import (
+	"sync"
+
+	"github.com/spotify-genre-organizer/backend/internal/database"
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// OverrideStore reads and writes the playlist_overrides that record which
+// Spotify playlist belongs to which genre and which tracks organize put there
+type OverrideStore interface {
+	GetPlaylistOverrides(userID string) (map[string]*models.PlaylistOverride, error)
+	SavePlaylistOverride(override *models.PlaylistOverride) error
+	DeletePlaylistOverride(userID, playlistID string) error
+}
+
+type databaseOverrides struct{}
+
+func (databaseOverrides) GetPlaylistOverrides(userID string) (map[string]*models.PlaylistOverride, error) {
+	return database.GetPlaylistOverrides(userID)
+}
+
+func (databaseOverrides) SavePlaylistOverride(override *models.PlaylistOverride) error {
+	return database.SavePlaylistOverride(override)
+}
+
+func (databaseOverrides) DeletePlaylistOverride(userID, playlistID string) error {
+	return database.DeletePlaylistOverride(userID, playlistID)
+}
+
+var overrides OverrideStore = databaseOverrides{}
+
+// SetOverrideStore replaces where organize keeps playlist overrides, e.g.
+// with an in-memory store when no database is configured
+func SetOverrideStore(store OverrideStore) {
+	overrides = store
+}
+
+// MemoryOverrideStore is an in-process OverrideStore for tests and for
+// running without a database
+type MemoryOverrideStore struct {
+	mu        sync.Mutex
+	overrides map[string]models.PlaylistOverride // by playlist ID
+}
+
+func NewMemoryOverrideStore() *MemoryOverrideStore {
+	return &MemoryOverrideStore{overrides: make(map[string]models.PlaylistOverride)}
+}
+
+func (m *MemoryOverrideStore) GetPlaylistOverrides(userID string) (map[string]*models.PlaylistOverride, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]*models.PlaylistOverride)
+	for id, o := range m.overrides {
+		if o.UserID == userID {
+			o := o
+			result[id] = &o
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryOverrideStore) SavePlaylistOverride(override *models.PlaylistOverride) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.overrides[override.PlaylistSpotifyID] = *override
+	return nil
+}
+
+func (m *MemoryOverrideStore) DeletePlaylistOverride(userID, playlistID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.overrides, playlistID)
+	return nil
+}
@@ -0,0 +1,158 @@
+package organizer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+// Plan edit operations
+const (
+	// EditMoveTracks moves TrackIDs into the ToGenre playlist
+	EditMoveTracks = "move_tracks"
+	// EditDropGenre removes the Genre playlist; its tracks are left unplaced
+	EditDropGenre = "drop_genre"
+	// EditRename changes the Genre playlist's Name and, if set, Description
+	EditRename = "rename"
+	// EditSetAction changes what applying the Genre playlist does, optionally
+	// pinning it to TargetPlaylistID
+	EditSetAction = "set_action"
+)
+
+// ErrInvalidEdit is returned by ApplyEdits for edits that don't fit the plan
+var ErrInvalidEdit = errors.New("invalid plan edit")
+
+// PlanEdit is a single change to an organize plan. Playlists are identified
+// by their genre.
+type PlanEdit struct {
+	Op               string   `json:"op"`
+	Genre            string   `json:"genre,omitempty"`
+	ToGenre          string   `json:"to_genre,omitempty"`
+	TrackIDs         []string `json:"track_ids,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Description      *string  `json:"description,omitempty"`
+	Action           string   `json:"action,omitempty"`
+	TargetPlaylistID *string  `json:"target_playlist_id,omitempty"`
+}
+
+// ApplyEdits applies edits to plan in order. Either every edit is applied or,
+// if one is invalid, the plan is left unchanged and an error wrapping
+// ErrInvalidEdit says which.
+func ApplyEdits(plan *models.OrganizePlan, edits []PlanEdit) error {
+	edited := copyPlaylists(plan.Playlists)
+	unplaced := plan.UnplacedTracks
+
+	for i, edit := range edits {
+		var err error
+		edited, unplaced, err = applyEdit(edited, unplaced, edit)
+		if err != nil {
+			return fmt.Errorf("%w: operation %d (%s): %v", ErrInvalidEdit, i+1, edit.Op, err)
+		}
+	}
+
+	plan.Playlists = edited
+	plan.UnplacedTracks = unplaced
+	return nil
+}
+
+func applyEdit(playlists []models.PlannedPlaylist, unplaced int, edit PlanEdit) ([]models.PlannedPlaylist, int, error) {
+	switch edit.Op {
+	case EditMoveTracks:
+		to := findPlanned(playlists, edit.ToGenre)
+		if to < 0 {
+			return nil, 0, fmt.Errorf("genre %q is not in the plan", edit.ToGenre)
+		}
+		if len(edit.TrackIDs) == 0 {
+			return nil, 0, errors.New("no tracks to move")
+		}
+
+		for _, id := range edit.TrackIDs {
+			from, pos := findPlannedTrack(playlists, id)
+			if from < 0 {
+				return nil, 0, fmt.Errorf("track %q is not in the plan", id)
+			}
+			if from == to {
+				continue
+			}
+			track := playlists[from].Tracks[pos]
+			playlists[from].Tracks = append(playlists[from].Tracks[:pos], playlists[from].Tracks[pos+1:]...)
+			playlists[to].Tracks = append(playlists[to].Tracks, track)
+		}
+		return playlists, unplaced, nil
+
+	case EditDropGenre:
+		i := findPlanned(playlists, edit.Genre)
+		if i < 0 {
+			return nil, 0, fmt.Errorf("genre %q is not in the plan", edit.Genre)
+		}
+		unplaced += len(playlists[i].Tracks)
+		return append(playlists[:i], playlists[i+1:]...), unplaced, nil
+
+	case EditRename:
+		i := findPlanned(playlists, edit.Genre)
+		if i < 0 {
+			return nil, 0, fmt.Errorf("genre %q is not in the plan", edit.Genre)
+		}
+		name := strings.TrimSpace(edit.Name)
+		if name == "" {
+			return nil, 0, errors.New("name is required")
+		}
+		playlists[i].Name = name
+		if edit.Description != nil {
+			playlists[i].Description = *edit.Description
+		}
+		return playlists, unplaced, nil
+
+	case EditSetAction:
+		i := findPlanned(playlists, edit.Genre)
+		if i < 0 {
+			return nil, 0, fmt.Errorf("genre %q is not in the plan", edit.Genre)
+		}
+		switch edit.Action {
+		case models.PlanActionCreate, models.PlanActionReplace, models.PlanActionAppend:
+		default:
+			return nil, 0, fmt.Errorf("unknown action %q", edit.Action)
+		}
+		playlists[i].Action = edit.Action
+		if edit.TargetPlaylistID != nil {
+			playlists[i].TargetPlaylistID = *edit.TargetPlaylistID
+		}
+		if edit.Action == models.PlanActionCreate {
+			playlists[i].TargetPlaylistID = ""
+		}
+		return playlists, unplaced, nil
+	}
+
+	return nil, 0, fmt.Errorf("unknown operation %q", edit.Op)
+}
+
+func findPlanned(playlists []models.PlannedPlaylist, genre string) int {
+	for i := range playlists {
+		if playlists[i].Genre == genre {
+			return i
+		}
+	}
+	return -1
+}
+
+func findPlannedTrack(playlists []models.PlannedPlaylist, trackID string) (playlist, pos int) {
+	for i := range playlists {
+		for j, t := range playlists[i].Tracks {
+			if t.ID == trackID {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+func copyPlaylists(playlists []models.PlannedPlaylist) []models.PlannedPlaylist {
+	cp := make([]models.PlannedPlaylist, len(playlists))
+	for i, p := range playlists {
+		p.Tracks = append([]models.PlannedTrack(nil), p.Tracks...)
+		cp[i] = p
+	}
+	return cp
+}
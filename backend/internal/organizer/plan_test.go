@@ -0,0 +1,72 @@
+package organizer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+)
+
+func testPlan() *models.OrganizePlan {
+	tracks := func(ids ...string) []models.PlannedTrack {
+		var t []models.PlannedTrack
+		for _, id := range ids {
+			t = append(t, models.PlannedTrack{ID: id})
+		}
+		return t
+	}
+	return &models.OrganizePlan{
+		Status: models.PlanStatusDraft,
+		Playlists: []models.PlannedPlaylist{
+			{Genre: "Rock", Name: "Rock by Organizer", Action: models.PlanActionCreate, Tracks: tracks("r1", "r2", "r3")},
+			{Genre: "Jazz", Name: "Jazz by Organizer", Action: models.PlanActionCreate, Tracks: tracks("j1", "j2")},
+			{Genre: "Pop", Name: "Pop by Organizer", Action: models.PlanActionCreate, Tracks: tracks("p1")},
+		},
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	plan := testPlan()
+	description := "Hand-picked"
+	target := "existing-jazz"
+
+	err := ApplyEdits(plan, []PlanEdit{
+		{Op: EditMoveTracks, TrackIDs: []string{"r3"}, ToGenre: "Jazz"},
+		{Op: EditDropGenre, Genre: "Pop"},
+		{Op: EditRename, Genre: "Rock", Name: "  Guitars  ", Description: &description},
+		{Op: EditSetAction, Genre: "Jazz", Action: models.PlanActionAppend, TargetPlaylistID: &target},
+	})
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+
+	if len(plan.Playlists) != 2 || plan.UnplacedTracks != 1 {
+		t.Fatalf("expected Pop dropped with 1 unplaced track, got %d playlists / %d", len(plan.Playlists), plan.UnplacedTracks)
+	}
+	rock, jazz := plan.Playlists[0], plan.Playlists[1]
+	if rock.Name != "Guitars" || rock.Description != description || len(rock.Tracks) != 2 {
+		t.Errorf("unexpected Rock playlist %+v", rock)
+	}
+	if got := jazz.TrackIDs(); len(got) != 3 || got[2] != "r3" {
+		t.Errorf("expected r3 moved to the end of Jazz, got %v", got)
+	}
+	if jazz.Action != models.PlanActionAppend || jazz.TargetPlaylistID != target {
+		t.Errorf("expected Jazz to append to %s, got %s to %q", target, jazz.Action, jazz.TargetPlaylistID)
+	}
+}
+
+func TestApplyEditsIsAllOrNothing(t *testing.T) {
+	plan := testPlan()
+
+	err := ApplyEdits(plan, []PlanEdit{
+		{Op: EditDropGenre, Genre: "Pop"},
+		{Op: EditMoveTracks, TrackIDs: []string{"missing"}, ToGenre: "Jazz"},
+	})
+	if !errors.Is(err, ErrInvalidEdit) {
+		t.Fatalf("expected ErrInvalidEdit, got %v", err)
+	}
+
+	if len(plan.Playlists) != 3 || plan.UnplacedTracks != 0 {
+		t.Errorf("expected the plan to be unchanged, got %d playlists / %d unplaced", len(plan.Playlists), plan.UnplacedTracks)
+	}
+}
@@ -0,0 +1,161 @@
+package organizer
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// playlistTargets finds the playlist each genre was written to before, so
+// re-running organize updates it no matter what it is called now
+type playlistTargets struct {
+	client *spotify.Client
+	userID string
+	byName *spotify.PlaylistLookup
+
+	byPlaylist map[string]*models.PlaylistOverride
+	byGenre    map[string][]*models.PlaylistOverride // most recently synced first
+}
+
+func loadPlaylistTargets(client *spotify.Client, userID string) *playlistTargets {
+	t := &playlistTargets{
+		client:     client,
+		userID:     userID,
+		byName:     client.NewPlaylistLookup(userID),
+		byPlaylist: make(map[string]*models.PlaylistOverride),
+		byGenre:    make(map[string][]*models.PlaylistOverride),
+	}
+
+	stored, err := overrides.GetPlaylistOverrides(userID)
+	if err != nil {
+		log.Printf("Failed to load playlist overrides for %s: %v", userID, err)
+		return t
+	}
+
+	for id, override := range stored {
+		t.byPlaylist[id] = override
+		t.byGenre[override.Genre] = append(t.byGenre[override.Genre], override)
+	}
+	for _, list := range t.byGenre {
+		sort.Slice(list, func(i, j int) bool {
+			return syncedAt(list[i]).After(syncedAt(list[j]))
+		})
+	}
+	return t
+}
+
+func syncedAt(o *models.PlaylistOverride) time.Time {
+	if o.LastSyncedAt != nil {
+		return *o.LastSyncedAt
+	}
+	return o.UpdatedAt
+}
+
+// find resolves the playlist a replace/append writes to, in order: the
+// plan's pinned target, the playlist stored for the genre, and finally a
+// playlist with the planned name, which adopts playlists made before their
+// IDs were recorded. It returns nil if there is none.
+func (t *playlistTargets) find(ctx context.Context, planned *models.PlannedPlaylist) (*spotify.Playlist, error) {
+	if planned.TargetPlaylistID != "" {
+		playlist, err := t.resolve(ctx, planned.TargetPlaylistID)
+		if err != nil || playlist != nil {
+			return playlist, err
+		}
+	}
+
+	for _, override := range t.byGenre[planned.Genre] {
+		playlist, err := t.resolve(ctx, override.PlaylistSpotifyID)
+		if err != nil || playlist != nil {
+			return playlist, err
+		}
+	}
+
+	// Check for existing playlist
+	return t.byName.Find(ctx, planned.Name)
+}
+
+// resolve fetches a playlist the user owns and still has in their library,
+// forgetting stored playlists that aren't
+func (t *playlistTargets) resolve(ctx context.Context, playlistID string) (*spotify.Playlist, error) {
+	playlist, err := t.client.GetPlaylist(ctx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	if playlist != nil && playlist.OwnerID == t.userID {
+		following, err := t.client.IsFollowingPlaylist(ctx, playlistID)
+		if err != nil {
+			return nil, err
+		}
+		if following {
+			return playlist, nil
+		}
+	}
+
+	if _, stored := t.byPlaylist[playlistID]; stored {
+		t.forget(playlistID)
+	}
+	return nil, nil
+}
+
+// policy returns the sync policy stored for a playlist, which is mirror for
+// playlists organize has no record of
+func (t *playlistTargets) policy(playlistID string) string {
+	if override, ok := t.byPlaylist[playlistID]; ok {
+		return override.Policy()
+	}
+	return models.SyncPolicyMirror
+}
+
+func (t *playlistTargets) forget(playlistID string) {
+	t.unlink(t.byPlaylist[playlistID])
+	delete(t.byPlaylist, playlistID)
+
+	if err := overrides.DeletePlaylistOverride(t.userID, playlistID); err != nil {
+		log.Printf("Failed to delete playlist override for %s: %v", playlistID, err)
+	}
+}
+
+// unlink removes the override from its genre's list
+func (t *playlistTargets) unlink(override *models.PlaylistOverride) {
+	list := t.byGenre[override.Genre]
+	for i, o := range list {
+		if o == override {
+			t.byGenre[override.Genre] = append(list[:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// record remembers that the playlist now holds the genre, keeping any custom
+// name or description the user gave it, and which tracks organize added. If
+// the playlist was created or replaced, those are the only managed tracks.
+func (t *playlistTargets) record(playlistID, genre string, added []string, replaced bool) error {
+	override, ok := t.byPlaylist[playlistID]
+	if ok {
+		t.unlink(override)
+	} else {
+		override = &models.PlaylistOverride{
+			UserID:            t.userID,
+			PlaylistSpotifyID: playlistID,
+		}
+		t.byPlaylist[playlistID] = override
+	}
+
+	now := time.Now()
+	override.Genre = genre
+	override.LastSyncedAt = &now
+	switch {
+	case replaced:
+		override.ManagedTracks = append([]string{}, added...)
+	case override.ManagedTracks != nil:
+		// Without a record every track already counts as managed
+		override.ManagedTracks = append(override.ManagedTracks, added...)
+	}
+	t.byGenre[genre] = append([]*models.PlaylistOverride{override}, t.byGenre[genre]...)
+
+	return overrides.SavePlaylistOverride(override)
+}
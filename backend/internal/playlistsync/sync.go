@@ -0,0 +1,248 @@
+// Package playlistsync brings a playlist's tracks in line with the tracks it
+// should hold by applying only the difference, so tracks that stay keep their
+// position and "date added" in Spotify.
+package playlistsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// ErrFrozen is returned by Sync for a playlist whose sync policy is frozen
+var ErrFrozen = errors.New("playlist is frozen")
+
+// Result reports what syncing one playlist changed
+type Result struct {
+	PlaylistID string   `json:"playlist_id"`
+	Added      []string `json:"added"`
+	Removed    []string `json:"removed"`
+	Unchanged  int      `json:"unchanged"`
+	SnapshotID string   `json:"snapshot_id,omitempty"`
+
+	// Unliked lists tracks found in the playlist that are no longer liked.
+	// Unless they were kept, they are also listed in Removed.
+	Unliked []string `json:"unliked"`
+
+	// UserOwned lists tracks in the playlist that sync didn't put there,
+	// which it leaves alone
+	UserOwned []string `json:"user_owned"`
+
+	// Managed lists the tracks sync put in the playlist that it now holds,
+	// to remember for the next sync
+	Managed []string `json:"-"`
+}
+
+// Options says how Sync treats tracks in the playlist that aren't desired
+type Options struct {
+	// Liked reports whether a track is still in the user's library. Without
+	// it, every track that isn't desired is removed.
+	Liked func(trackID string) bool
+
+	// Unliked is one of the models.Unliked* settings. UnlikedKeep leaves
+	// tracks that are no longer liked in place; otherwise they are removed,
+	// and archiving them is up to the caller.
+	Unliked string
+
+	// Managed lists the tracks sync (or organize) put in the playlist
+	// before. Any other track was added by the user and is never removed.
+	// Nil means nothing was recorded yet, so every track counts as managed.
+	Managed []string
+
+	// AppendOnly only adds tracks, leaving every track already in the
+	// playlist in place whether or not it is desired or liked
+	AppendOnly bool
+
+	// Frozen leaves the playlist untouched; Sync returns ErrFrozen without
+	// reading it
+	Frozen bool
+}
+
+// Changed reports whether the sync added or removed anything
+func (r *Result) Changed() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0
+}
+
+// Diff compares a playlist's current tracks with the tracks it should hold.
+// add lists desired tracks that are missing, in desired order; remove lists
+// current tracks that aren't desired, once each, in playlist order.
+func Diff(current, desired []string) (add, remove []string, unchanged int) {
+	want := make(map[string]bool, len(desired))
+	for _, id := range desired {
+		want[id] = true
+	}
+
+	have := make(map[string]bool, len(current))
+	for _, id := range current {
+		if have[id] {
+			continue
+		}
+		have[id] = true
+		if want[id] {
+			unchanged++
+		} else {
+			remove = append(remove, id)
+		}
+	}
+
+	added := make(map[string]bool)
+	for _, id := range desired {
+		if !have[id] && !added[id] {
+			added[id] = true
+			add = append(add, id)
+		}
+	}
+
+	return add, remove, unchanged
+}
+
+// Sync reads the playlist's tracks and removes and adds tracks, in batches,
+// until it holds desired, plus any tracks no longer liked that opts says to
+// keep, or every track it held when opts is AppendOnly. Tracks the user
+// added themselves are always kept. New tracks are appended at the end.
+func Sync(ctx context.Context, client *spotify.Client, playlistID string, desired []string, opts Options) (*Result, error) {
+	if opts.Frozen {
+		return nil, ErrFrozen
+	}
+
+	current, err := client.GetPlaylistTrackIDs(ctx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	add, remove, unchanged := Diff(current, desired)
+	result := &Result{
+		PlaylistID: playlistID,
+		Added:      []string{},
+		Removed:    []string{},
+		Unliked:    []string{},
+		UserOwned:  []string{},
+		Unchanged:  unchanged,
+	}
+
+	ours, userOwned := Ownership(current, opts.Managed)
+	result.UserOwned = append(result.UserOwned, userOwned...)
+	managed := make(map[string]bool, len(ours))
+	for _, id := range ours {
+		managed[id] = true
+	}
+
+	if opts.AppendOnly {
+		remove = nil
+	}
+
+	// Never remove tracks the user added
+	removable := remove[:0]
+	for _, id := range remove {
+		if managed[id] {
+			removable = append(removable, id)
+		}
+	}
+	remove = removable
+
+	if opts.Liked != nil {
+		kept := remove[:0]
+		for _, id := range remove {
+			if opts.Liked(id) {
+				kept = append(kept, id)
+				continue
+			}
+			result.Unliked = append(result.Unliked, id)
+			if opts.Unliked != models.UnlikedKeep {
+				kept = append(kept, id)
+			}
+		}
+		remove = kept
+	}
+
+	if len(remove) > 0 {
+		snapshotID, err := client.RemoveTracksFromPlaylist(ctx, playlistID, remove)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = remove
+		result.SnapshotID = snapshotID
+	}
+
+	if len(add) > 0 {
+		snapshotID, err := client.AddTracksToPlaylist(ctx, playlistID, add)
+		if err != nil {
+			return result, fmt.Errorf("after removing %d tracks: %w", len(result.Removed), err)
+		}
+		result.Added = add
+		result.SnapshotID = snapshotID
+	}
+
+	// Remember the managed tracks that stayed, in playlist order, then the
+	// ones just added
+	gone := make(map[string]bool, len(result.Removed))
+	for _, id := range result.Removed {
+		gone[id] = true
+	}
+	result.Managed = []string{}
+	for _, id := range ours {
+		if !gone[id] {
+			result.Managed = append(result.Managed, id)
+		}
+	}
+	result.Managed = append(result.Managed, result.Added...)
+
+	return result, nil
+}
+
+// Ownership splits a playlist's tracks into those sync put there, going by
+// the recorded managed tracks, and those the user added. Each track is
+// listed once, in playlist order. Without a record every track is managed.
+func Ownership(current, recorded []string) (managed, userOwned []string) {
+	ours := make(map[string]bool, len(recorded))
+	for _, id := range recorded {
+		ours[id] = true
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, id := range current {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if recorded == nil || ours[id] {
+			managed = append(managed, id)
+		} else {
+			userOwned = append(userOwned, id)
+		}
+	}
+	return managed, userOwned
+}
+
+// Archive adds the tracks the playlist doesn't hold yet at its end, leaving
+// everything else alone
+func Archive(ctx context.Context, client *spotify.Client, playlistID string, trackIDs []string) (*Result, error) {
+	current, err := client.GetPlaylistTrackIDs(ctx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	add, _, unchanged := Diff(current, trackIDs)
+	result := &Result{
+		PlaylistID: playlistID,
+		Added:      []string{},
+		Removed:    []string{},
+		Unliked:    []string{},
+		Unchanged:  unchanged,
+	}
+
+	if len(add) > 0 {
+		snapshotID, err := client.AddTracksToPlaylist(ctx, playlistID, add)
+		if err != nil {
+			return result, err
+		}
+		result.Added = add
+		result.SnapshotID = snapshotID
+	}
+
+	return result, nil
+}
@@ -0,0 +1,225 @@
+package playlistsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spotify-genre-organizer/backend/internal/models"
+	"github.com/spotify-genre-organizer/backend/internal/spotify/spotifytest"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		current, desired []string
+		add, remove      []string
+		unchanged        int
+	}{
+		{nil, []string{"a", "b"}, []string{"a", "b"}, nil, 0},
+		{[]string{"a", "b"}, nil, nil, []string{"a", "b"}, 0},
+		{[]string{"a", "b", "c", "d"}, []string{"d", "b", "e"}, []string{"e"}, []string{"a", "c"}, 2},
+		// Duplicates are neither re-added nor removed twice
+		{[]string{"a", "a", "x", "x"}, []string{"a", "b", "b"}, []string{"b"}, []string{"x"}, 1},
+	}
+
+	for _, tt := range tests {
+		add, remove, unchanged := Diff(tt.current, tt.desired)
+		if !reflect.DeepEqual(add, tt.add) || !reflect.DeepEqual(remove, tt.remove) || unchanged != tt.unchanged {
+			t.Errorf("Diff(%v, %v) = %v, %v, %d; want %v, %v, %d",
+				tt.current, tt.desired, add, remove, unchanged, tt.add, tt.remove, tt.unchanged)
+		}
+	}
+}
+
+func TestSyncAppliesOnlyTheDelta(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	token := srv.AddUser("alice")
+	client := srv.Config().NewClient(token)
+
+	var current, desired []string
+	for i := 0; i < 250; i++ {
+		current = append(current, fmt.Sprintf("t%d", i))
+	}
+	// Keep every other track and add 120 new ones
+	for i := 0; i < 250; i += 2 {
+		desired = append(desired, fmt.Sprintf("t%d", i))
+	}
+	for i := 0; i < 120; i++ {
+		desired = append(desired, fmt.Sprintf("new%d", i))
+	}
+	playlistID := srv.AddPlaylist("alice", "Rock", current...)
+	before, _ := srv.Playlist(playlistID)
+
+	result, err := Sync(context.Background(), client, playlistID, desired, Options{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if len(result.Added) != 120 || len(result.Removed) != 125 || result.Unchanged != 125 || result.SnapshotID == "" {
+		t.Errorf("expected 120 added, 125 removed, 125 unchanged with a snapshot, got %d/%d/%d %q",
+			len(result.Added), len(result.Removed), result.Unchanged, result.SnapshotID)
+	}
+
+	after, _ := srv.Playlist(playlistID)
+	if !reflect.DeepEqual(after.TrackIDs(), desired) {
+		t.Errorf("expected the playlist to hold the desired tracks in order")
+	}
+	if !after.Tracks[0].AddedAt.Equal(before.Tracks[0].AddedAt) {
+		t.Errorf("expected kept tracks to keep their date added")
+	}
+
+	for _, req := range srv.Requests() {
+		if strings.HasPrefix(req, "PUT ") {
+			t.Errorf("expected no full replacement, got %s", req)
+		}
+	}
+}
+
+func TestSyncWithNothingToDo(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	token := srv.AddUser("alice")
+	client := srv.Config().NewClient(token)
+
+	playlistID := srv.AddPlaylist("alice", "Rock", "a", "b")
+
+	result, err := Sync(context.Background(), client, playlistID, []string{"b", "a"}, Options{})
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if result.Changed() || srv.RequestCount() != 1 {
+		t.Errorf("expected a single read and no changes, got %+v after %d requests", result, srv.RequestCount())
+	}
+}
+
+func TestSyncHandlesUnlikedTracks(t *testing.T) {
+	tests := []struct {
+		unliked string
+		removed []string
+		left    []string
+	}{
+		{models.UnlikedKeep, []string{"other"}, []string{"a", "gone"}},
+		{models.UnlikedRemove, []string{"gone", "other"}, []string{"a"}},
+		{models.UnlikedArchive, []string{"gone", "other"}, []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.unliked, func(t *testing.T) {
+			srv := spotifytest.NewServer()
+			defer srv.Close()
+			client := srv.Config().NewClient(srv.AddUser("alice"))
+
+			// "gone" was unliked; "other" is still liked but moved genre
+			playlistID := srv.AddPlaylist("alice", "Rock", "a", "gone", "other")
+			opts := Options{
+				Liked:   func(id string) bool { return id != "gone" },
+				Unliked: tt.unliked,
+			}
+
+			result, err := Sync(context.Background(), client, playlistID, []string{"a"}, opts)
+			if err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Unliked, []string{"gone"}) {
+				t.Errorf("expected gone to be reported as unliked, got %v", result.Unliked)
+			}
+			if !reflect.DeepEqual(result.Removed, tt.removed) {
+				t.Errorf("expected %v removed, got %v", tt.removed, result.Removed)
+			}
+			p, _ := srv.Playlist(playlistID)
+			if !reflect.DeepEqual(p.TrackIDs(), tt.left) {
+				t.Errorf("expected %v left in the playlist, got %v", tt.left, p.TrackIDs())
+			}
+		})
+	}
+}
+
+func TestSyncAppendOnlyOnlyAdds(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	client := srv.Config().NewClient(srv.AddUser("alice"))
+
+	playlistID := srv.AddPlaylist("alice", "Rock", "a", "gone", "other")
+	opts := Options{
+		Liked:      func(id string) bool { return id != "gone" },
+		Unliked:    models.UnlikedRemove,
+		AppendOnly: true,
+	}
+
+	result, err := Sync(context.Background(), client, playlistID, []string{"a", "new"}, opts)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if len(result.Removed) != 0 || len(result.Unliked) != 0 {
+		t.Errorf("expected nothing removed, got removed %v and unliked %v", result.Removed, result.Unliked)
+	}
+	p, _ := srv.Playlist(playlistID)
+	if want := []string{"a", "gone", "other", "new"}; !reflect.DeepEqual(p.TrackIDs(), want) {
+		t.Errorf("expected %v, got %v", want, p.TrackIDs())
+	}
+}
+
+func TestSyncFrozenLeavesPlaylistAlone(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	client := srv.Config().NewClient(srv.AddUser("alice"))
+
+	playlistID := srv.AddPlaylist("alice", "Rock", "a", "gone")
+	before := srv.RequestCount()
+
+	_, err := Sync(context.Background(), client, playlistID, []string{"a", "new"}, Options{Frozen: true})
+	if !errors.Is(err, ErrFrozen) {
+		t.Fatalf("expected ErrFrozen, got %v", err)
+	}
+
+	if got := srv.RequestCount(); got != before {
+		t.Errorf("expected no requests for a frozen playlist, got %d", got-before)
+	}
+	p, _ := srv.Playlist(playlistID)
+	if want := []string{"a", "gone"}; !reflect.DeepEqual(p.TrackIDs(), want) {
+		t.Errorf("expected %v, got %v", want, p.TrackIDs())
+	}
+}
+
+func TestSyncKeepsTracksTheUserAdded(t *testing.T) {
+	srv := spotifytest.NewServer()
+	defer srv.Close()
+	client := srv.Config().NewClient(srv.AddUser("alice"))
+	ctx := context.Background()
+
+	// Nothing recorded yet, so every track counts as added by sync
+	playlistID := srv.AddPlaylist("alice", "Jazz", "a", "b")
+	result, err := Sync(ctx, client, playlistID, []string{"a", "c"}, Options{})
+	if err != nil {
+		t.Fatalf("first Sync: %v", err)
+	}
+	if !reflect.DeepEqual(result.Managed, []string{"a", "c"}) || len(result.UserOwned) != 0 {
+		t.Fatalf("expected a and c to be managed, got %v and user owned %v", result.Managed, result.UserOwned)
+	}
+
+	// The user adds a track by hand, and the genre moves on
+	if _, err := client.AddTracksToPlaylist(ctx, playlistID, []string{"mine"}); err != nil {
+		t.Fatalf("AddTracksToPlaylist: %v", err)
+	}
+	result, err = Sync(ctx, client, playlistID, []string{"c", "d"}, Options{Managed: result.Managed})
+	if err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Removed, []string{"a"}) || !reflect.DeepEqual(result.UserOwned, []string{"mine"}) {
+		t.Errorf("expected only a removed and mine user owned, got %v and %v", result.Removed, result.UserOwned)
+	}
+	if !reflect.DeepEqual(result.Managed, []string{"c", "d"}) {
+		t.Errorf("expected c and d to be managed, got %v", result.Managed)
+	}
+	p, _ := srv.Playlist(playlistID)
+	if want := []string{"c", "mine", "d"}; !reflect.DeepEqual(p.TrackIDs(), want) {
+		t.Errorf("expected %v, got %v", want, p.TrackIDs())
+	}
+}
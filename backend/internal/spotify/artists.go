@@ -1,12 +1,12 @@
 package spotify
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 )
 
 type ArtistDetails struct {
@@ -19,7 +19,7 @@ type artistsResponse struct {
 	Artists []ArtistDetails `json:"artists"`
 }
 
-func FetchArtists(accessToken string, artistIDs []string) ([]ArtistDetails, error) {
+func (c *Client) FetchArtists(ctx context.Context, artistIDs []string) ([]ArtistDetails, error) {
 	if len(artistIDs) == 0 {
 		return nil, nil
 	}
@@ -28,17 +28,14 @@ func FetchArtists(accessToken string, artistIDs []string) ([]ArtistDetails, erro
 		artistIDs = artistIDs[:50]
 	}
 
-	url := fmt.Sprintf("%s/artists?ids=%s", APIURL, strings.Join(artistIDs, ","))
+	url := fmt.Sprintf("%s/artists?ids=%s", c.apiURL, strings.Join(artistIDs, ","))
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -61,45 +58,86 @@ func FetchArtists(accessToken string, artistIDs []string) ([]ArtistDetails, erro
 	return result.Artists, nil
 }
 
-func FetchAllArtistGenres(accessToken string, songs []Song, progressCallback func(processed, total int)) (map[string][]string, error) {
+// ArtistGenreCache remembers the genres of artists across requests and
+// users. Get returns the genres of the artists it has fresh copies of, by
+// artist ID, leaving the others out; an artist without genres is present with
+// an empty list.
+type ArtistGenreCache interface {
+	Get(artistIDs []string) map[string][]string
+	Set(artistGenres map[string][]string)
+}
+
+// FetchAllArtistGenres returns the genres of every artist of songs, by
+// artist ID. Artists found in the client's ArtistGenreCache aren't fetched;
+// the others are fetched in concurrent batches.
+func (c *Client) FetchAllArtistGenres(ctx context.Context, songs []Song, progressCallback func(processed, total int)) (map[string][]string, error) {
 	artistSet := make(map[string]bool)
+	var artistIDs []string
 	for _, song := range songs {
 		for _, artist := range song.Artists {
-			artistSet[artist.ID] = true
+			if !artistSet[artist.ID] {
+				artistSet[artist.ID] = true
+				artistIDs = append(artistIDs, artist.ID)
+			}
 		}
 	}
 
-	artistIDs := make([]string, 0, len(artistSet))
-	for id := range artistSet {
-		artistIDs = append(artistIDs, id)
+	genreMap := make(map[string][]string)
+	progress := &progressCounter{total: len(artistIDs), callback: progressCallback}
+
+	if c.artistGenres != nil && len(artistIDs) > 0 {
+		cached := c.artistGenres.Get(artistIDs)
+		missing := artistIDs[:0]
+		for _, id := range artistIDs {
+			if genres, ok := cached[id]; ok {
+				genreMap[id] = genres
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		artistIDs = missing
+		if len(cached) > 0 {
+			progress.add(len(cached))
+		}
 	}
 
-	genreMap := make(map[string][]string)
 	batchSize := 50
-	total := len(artistIDs)
+	batches := make([][]ArtistDetails, (len(artistIDs)+batchSize-1)/batchSize)
 
-	for i := 0; i < len(artistIDs); i += batchSize {
-		end := i + batchSize
-		if end > len(artistIDs) {
-			end = len(artistIDs)
-		}
+	err := forEach(ctx, len(batches), c.concurrency, func(ctx context.Context, i int) error {
+		start := i * batchSize
+		end := min(start+batchSize, len(artistIDs))
 
-		batch := artistIDs[i:end]
-		artists, err := FetchArtists(accessToken, batch)
+		artists, err := c.FetchArtists(ctx, artistIDs[start:end])
 		if err != nil {
-			return nil, err
+			return err
 		}
-
+		batches[i] = artists
+		progress.add(end - start)
+		return nil
+	})
+
+	// Keep whatever was fetched, even if a batch failed
+	fetched := make(map[string][]string)
+	for _, artists := range batches {
 		for _, artist := range artists {
+			if artist.Genres == nil {
+				artist.Genres = []string{}
+			}
 			genreMap[artist.ID] = artist.Genres
+			fetched[artist.ID] = artist.Genres
 		}
-
-		if progressCallback != nil {
-			progressCallback(end, total)
-		}
-
-		time.Sleep(100 * time.Millisecond)
 	}
+	c.cacheArtistGenres(fetched)
 
+	if err != nil {
+		return nil, err
+	}
 	return genreMap, nil
 }
+
+func (c *Client) cacheArtistGenres(fetched map[string][]string) {
+	if c.artistGenres != nil && len(fetched) > 0 {
+		c.artistGenres.Set(fetched)
+	}
+}
@@ -1,13 +1,42 @@
 package spotify
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type Config struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
+
+	// Endpoints default to Spotify's production URLs and can be pointed at a
+	// fake (see spotifytest) for offline testing
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+
+	// ArtistGenres, if set, is consulted by FetchAllArtistGenres before
+	// asking Spotify
+	ArtistGenres ArtistGenreCache
+
+	// Concurrency caps how many pages or batches a client fetches at once;
+	// zero means DefaultConcurrency
+	Concurrency int
+
+	// MaxRetries caps how often a failed request is retried; zero means the
+	// default of 5
+	MaxRetries int
 }
 
 func NewConfig() *Config {
@@ -15,13 +44,72 @@ func NewConfig() *Config {
 		ClientID:     os.Getenv("SPOTIFY_CLIENT_ID"),
 		ClientSecret: os.Getenv("SPOTIFY_CLIENT_SECRET"),
 		RedirectURI:  os.Getenv("SPOTIFY_REDIRECT_URI"),
+		AuthURL:      envOrDefault("SPOTIFY_AUTH_URL", DefaultAuthURL),
+		TokenURL:     envOrDefault("SPOTIFY_TOKEN_URL", DefaultTokenURL),
+		APIURL:       envOrDefault("SPOTIFY_API_URL", DefaultAPIURL),
+		Concurrency:  envInt("SPOTIFY_CONCURRENCY"),
+	}
+}
+
+// NewClient returns a Web API client for accessToken using this config's
+// API base URL
+func (c *Config) NewClient(accessToken string) *Client {
+	client := NewClient(accessToken)
+	if c.APIURL != "" {
+		client.apiURL = strings.TrimRight(c.APIURL, "/")
+	}
+	client.artistGenres = c.ArtistGenres
+	if c.Concurrency > 0 {
+		client.concurrency = c.Concurrency
+	}
+	if c.MaxRetries > 0 {
+		client.maxRetries = c.MaxRetries
+	}
+	return client
+}
+
+// NewClientWithTokens returns a Web API client that asks source for the access
+// token to send, so it keeps working when the token is refreshed during the
+// client's life
+func (c *Config) NewClientWithTokens(source TokenSource) *Client {
+	client := c.NewClient("")
+	client.tokens = source
+	return client
+}
+
+// TokenSource supplies a client's access token when it changes over time
+type TokenSource interface {
+	// Token returns the access token to send, refreshing it first if it is
+	// about to expire
+	Token(ctx context.Context) (string, error)
+
+	// Refresh is called when Spotify rejects token and returns the token to
+	// retry with. If token was already replaced, the current one is returned
+	// without refreshing again.
+	Refresh(ctx context.Context, token string) (string, error)
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt reads a positive integer from the environment, returning zero if it
+// isn't set or isn't valid
+func envInt(key string) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return 0
 	}
+	return value
 }
 
 const (
-	AuthURL  = "https://accounts.spotify.com/authorize"
-	TokenURL = "https://accounts.spotify.com/api/token"
-	APIURL   = "https://api.spotify.com/v1"
+	DefaultAuthURL  = "https://accounts.spotify.com/authorize"
+	DefaultTokenURL = "https://accounts.spotify.com/api/token"
+	DefaultAPIURL   = "https://api.spotify.com/v1"
 )
 
 var Scopes = []string{
@@ -33,3 +121,243 @@ var Scopes = []string{
 	"user-read-email",
 	"user-read-private",
 }
+
+const (
+	defaultMaxRetries  = 5
+	defaultBaseBackoff = 500 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+
+	// maxRetryAfter is the longest Retry-After we are willing to sleep through.
+	// Anything longer is returned to the caller as a 429.
+	maxRetryAfter = 2 * time.Minute
+)
+
+// sharedHTTPClient is reused by every Client so connections are pooled
+var sharedHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Client sends Spotify Web API requests on behalf of a single access token,
+// or of a TokenSource whose token is refreshed once if Spotify rejects it.
+// Every request is paced by the process-wide rate limiter, 429 responses are
+// retried after their Retry-After delay, and 5xx/transport errors are retried
+// with jittered exponential backoff.
+type Client struct {
+	accessToken string
+	tokens      TokenSource
+	apiURL      string
+	httpClient  *http.Client
+	limiter     *rateLimiter
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	artistGenres ArtistGenreCache
+	concurrency  int
+
+	requests    atomic.Int64
+	retries     atomic.Int64
+	rateLimited atomic.Int64
+}
+
+// Stats reports how many requests a Client has made and how many of those
+// were retries
+type Stats struct {
+	Requests    int64 `json:"requests"`
+	Retries     int64 `json:"retries"`
+	RateLimited int64 `json:"rate_limited"`
+}
+
+// NewClient returns a client for the production Spotify API. Use
+// Config.NewClient to honour a configured base URL.
+func NewClient(accessToken string) *Client {
+	return &Client{
+		accessToken: accessToken,
+		apiURL:      DefaultAPIURL,
+		httpClient:  sharedHTTPClient,
+		limiter:     defaultLimiter,
+		maxRetries:  defaultMaxRetries,
+		baseBackoff: defaultBaseBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		concurrency: DefaultConcurrency,
+	}
+}
+
+// Stats returns the request and retry counters accumulated so far
+func (c *Client) Stats() Stats {
+	return Stats{
+		Requests:    c.requests.Load(),
+		Retries:     c.retries.Load(),
+		RateLimited: c.rateLimited.Load(),
+	}
+}
+
+// newRequest builds a request whose body (if any) is JSON-encoded and can be
+// replayed on retry
+func (c *Client) newRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// Do sends req, retrying rate-limited, 5xx and transport failures. The final
+// response is returned as-is so callers keep checking the status they expect.
+// Requests with a body must be replayable (http.NewRequest sets GetBody for
+// bytes and strings readers); otherwise they are sent only once. Waiting for
+// the rate limiter or a retry stops as soon as the request's context is done.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// token is set when it came from c.tokens and may be refreshed
+	var token string
+	if req.Header.Get("Authorization") == "" {
+		switch {
+		case c.tokens != nil:
+			var err error
+			if token, err = c.tokens.Token(ctx); err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		case c.accessToken != "":
+			req.Header.Set("Authorization", "Bearer "+c.accessToken)
+		}
+	}
+	refreshed := false
+
+	maxRetries := c.maxRetries
+	if req.Body != nil && req.GetBody == nil {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		if err := sleep(ctx, c.limiter.reserve()); err != nil {
+			return nil, err
+		}
+		c.requests.Add(1)
+
+		resp, err := c.httpClient.Do(req)
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if attempt >= maxRetries || ctx.Err() != nil {
+				return nil, err
+			}
+			wait = c.backoff(attempt)
+			log.Printf("spotify: %s %s failed (attempt %d): %v, retrying in %s", req.Method, req.URL.Path, attempt+1, err, wait)
+
+		case resp.StatusCode == http.StatusTooManyRequests:
+			c.rateLimited.Add(1)
+			wait = retryAfter(resp, c.backoff(attempt))
+			if attempt >= maxRetries || wait > maxRetryAfter {
+				return resp, nil
+			}
+			discard(resp)
+			log.Printf("spotify: %s %s rate limited, retrying in %s", req.Method, req.URL.Path, wait)
+			// Spotify limits per app, so hold back every client, not just this
+			// one. The limiter delays our own retry as well.
+			c.limiter.pause(wait)
+			wait = 0
+
+		case resp.StatusCode >= 500:
+			if attempt >= maxRetries {
+				return resp, nil
+			}
+			wait = c.backoff(attempt)
+			discard(resp)
+			log.Printf("spotify: %s %s returned %d (attempt %d), retrying in %s", req.Method, req.URL.Path, resp.StatusCode, attempt+1, wait)
+
+		case resp.StatusCode == http.StatusUnauthorized && token != "" && !refreshed && attempt < maxRetries:
+			// The token expired early or was revoked; retry once with a new one
+			discard(resp)
+			refreshed = true
+			if token, err = c.tokens.Refresh(ctx, token); err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			log.Printf("spotify: %s %s was unauthorized, retrying with a refreshed token", req.Method, req.URL.Path)
+
+		default:
+			return resp, nil
+		}
+
+		c.retries.Add(1)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns an exponential delay for the given attempt with equal jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.baseBackoff << uint(attempt)
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
+
+// retryAfter parses the Retry-After header (seconds or HTTP date), falling
+// back to the given delay when it is missing or malformed
+func retryAfter(resp *http.Response, fallback time.Duration) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return fallback
+}
+
+// discard drains and closes a response body so the connection can be reused
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
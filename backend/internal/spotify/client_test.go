@@ -0,0 +1,252 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient() *Client {
+	c := NewClient("test-token")
+	c.limiter = newRateLimiter(1000, 1000)
+	c.baseBackoff = time.Millisecond
+	c.maxBackoff = 5 * time.Millisecond
+	return c
+}
+
+func TestClientRetriesRateLimited(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("missing bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	stats := c.Stats()
+	if stats.Requests != 2 || stats.Retries != 1 || stats.RateLimited != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestClientRetriesServerErrorsWithBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"uris":["spotify:track:1"]}` {
+			t.Errorf("body not replayed, got %q", body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	req, err := c.newRequest(context.Background(), "POST", srv.URL, addTracksRequest{URIs: []string{"spotify:track:1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected 201, got %d", resp.StatusCode)
+	}
+	if got := c.Stats().Retries; got != 2 {
+		t.Errorf("expected 2 retries, got %d", got)
+	}
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	c.maxRetries = 2
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", resp.StatusCode)
+	}
+	if got := c.Stats().Requests; got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	req, _ := http.NewRequest("DELETE", srv.URL, strings.NewReader("{}"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single call, got %d", calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if got := retryAfter(resp, time.Second); got != time.Second {
+		t.Errorf("missing header: expected fallback, got %s", got)
+	}
+
+	resp.Header.Set("Retry-After", "7")
+	if got := retryAfter(resp, time.Second); got != 7*time.Second {
+		t.Errorf("expected 7s, got %s", got)
+	}
+
+	resp.Header.Set("Retry-After", "soon")
+	if got := retryAfter(resp, time.Second); got != time.Second {
+		t.Errorf("malformed header: expected fallback, got %s", got)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l := newRateLimiter(100, 5)
+	if d := l.reserve(); d != 0 {
+		t.Errorf("expected no delay within burst, got %s", d)
+	}
+
+	l.pause(200 * time.Millisecond)
+	if d := l.reserve(); d < 190*time.Millisecond {
+		t.Errorf("expected ~200ms delay after pause, got %s", d)
+	}
+}
+
+func TestClientStopsRetryingWhenContextDone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	c.baseBackoff = time.Hour
+	c.maxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	start := time.Now()
+	_, err := c.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do kept waiting after the deadline (%s)", elapsed)
+	}
+}
+
+// rotatingTokens hands out "old" until Spotify rejects it, then "new"
+type rotatingTokens struct {
+	current   string
+	refreshes int
+}
+
+func (r *rotatingTokens) Token(ctx context.Context) (string, error) {
+	return r.current, nil
+}
+
+func (r *rotatingTokens) Refresh(ctx context.Context, token string) (string, error) {
+	if token == r.current {
+		r.refreshes++
+		r.current = "new"
+	}
+	return r.current, nil
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tokens := &rotatingTokens{current: "old"}
+	c := newTestClient()
+	c.tokens = tokens
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	if tokens.refreshes != 1 {
+		t.Errorf("expected one refresh, got %d", tokens.refreshes)
+	}
+	if stats := c.Stats(); stats.Requests != 3 {
+		t.Errorf("expected the first request to be retried once, got %+v", stats)
+	}
+
+	// A refreshed token that is rejected as well isn't refreshed again
+	tokens.current = "revoked"
+	req, _ := http.NewRequest("GET", srv.URL+"/revoked", nil)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the 401 to be returned, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,81 @@
+package spotify
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultConcurrency is how many requests a client keeps in flight when
+// fetching pages or batches whose offsets are known up front. Every request
+// still waits for the shared rate limiter, so this only hides latency.
+const DefaultConcurrency = 4
+
+// forEach calls fetch for 0..n-1, running at most limit calls at once. After
+// the first error no further calls are started and the context passed to
+// running ones is cancelled; that error is returned once they have finished.
+func forEach(ctx context.Context, n, limit int, fetch func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		slots    = make(chan struct{}, limit)
+	)
+
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := fetch(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// progressCounter adds up work finished by concurrent fetches and reports the
+// running total, one call at a time and never going backwards
+type progressCounter struct {
+	mu        sync.Mutex
+	processed int
+	total     int
+	callback  func(processed, total int)
+}
+
+func (p *progressCounter) add(n int) {
+	if p.callback == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.processed += n
+	p.callback(p.processed, p.total)
+}
@@ -1,6 +1,7 @@
 package spotify
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -79,18 +80,15 @@ func ParseLikedSongsResponse(data []byte) ([]Song, int, string, error) {
 	return songs, resp.Total, next, nil
 }
 
-func FetchLikedSongs(accessToken string, limit, offset int) ([]Song, int, string, error) {
-	url := fmt.Sprintf("%s/me/tracks?limit=%d&offset=%d", APIURL, limit, offset)
+func (c *Client) FetchLikedSongs(ctx context.Context, limit, offset int) ([]Song, int, string, error) {
+	url := fmt.Sprintf("%s/me/tracks?limit=%d&offset=%d", c.apiURL, limit, offset)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, 0, "", err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return nil, 0, "", err
 	}
@@ -108,36 +106,75 @@ func FetchLikedSongs(accessToken string, limit, offset int) ([]Song, int, string
 	return ParseLikedSongsResponse(body)
 }
 
-func FetchAllLikedSongs(accessToken string, progressCallback func(processed, total int)) ([]Song, error) {
-	var allSongs []Song
+// FetchAllLikedSongs returns the whole library, newest first. The first page
+// gives the library's size, after which the remaining pages are fetched
+// concurrently.
+func (c *Client) FetchAllLikedSongs(ctx context.Context, progressCallback func(processed, total int)) ([]Song, error) {
 	limit := 50
-	offset := 0
-	total := 0
 
-	for {
-		songs, t, _, err := FetchLikedSongs(accessToken, limit, offset)
+	first, total, _, err := c.FetchLikedSongs(ctx, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &progressCounter{total: total, callback: progressCallback}
+	progress.add(len(first))
+
+	if len(first) < limit || len(first) >= total {
+		return first, nil
+	}
+
+	pages := make([][]Song, (total+limit-1)/limit)
+	pages[0] = first
+
+	err = forEach(ctx, len(pages)-1, c.concurrency, func(ctx context.Context, i int) error {
+		songs, _, _, err := c.FetchLikedSongs(ctx, limit, (i+1)*limit)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		pages[i+1] = songs
+		progress.add(len(songs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if total == 0 {
-			total = t
-		}
+	allSongs := make([]Song, 0, total)
+	for _, page := range pages {
+		allSongs = append(allSongs, page...)
+	}
 
-		allSongs = append(allSongs, songs...)
+	return allSongs, nil
+}
 
-		if progressCallback != nil {
-			progressCallback(len(allSongs), total)
+// FetchLikedSongsSince returns the songs liked at or after since, newest
+// first, along with the size of the whole library. Liked songs are listed
+// newest first, so paging stops at the first page reaching older songs.
+func (c *Client) FetchLikedSongsSince(ctx context.Context, since time.Time) ([]Song, int, error) {
+	var recent []Song
+	limit := 50
+	offset := 0
+
+	for {
+		songs, total, next, err := c.FetchLikedSongs(ctx, limit, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, song := range songs {
+			if song.AddedAt.Before(since) {
+				return recent, total, nil
+			}
+			recent = append(recent, song)
 		}
 
-		if len(songs) < limit || len(allSongs) >= total {
-			break
+		if next == "" || len(songs) < limit {
+			return recent, total, nil
 		}
 
 		offset += limit
 	}
-
-	return allSongs, nil
 }
 
 func EnrichSongsWithGenres(songs []Song, artistGenres map[string][]string) {
@@ -159,15 +196,13 @@ func EnrichSongsWithGenres(songs []Song, artistGenres map[string][]string) {
 }
 
 // GetLikedSongsCount returns the total count of user's liked songs
-func GetLikedSongsCount(accessToken string) (int, error) {
-	req, err := http.NewRequest("GET", APIURL+"/me/tracks?limit=1", nil)
+func (c *Client) GetLikedSongsCount(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+"/me/tracks?limit=1", nil)
 	if err != nil {
 		return 0, err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return 0, err
 	}
@@ -1,7 +1,15 @@
 package spotify
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestParseLikedSongsResponse(t *testing.T) {
@@ -45,3 +53,80 @@ func TestParseLikedSongsResponse(t *testing.T) {
 		t.Errorf("expected track ID track123, got %s", songs[0].ID)
 	}
 }
+
+func TestFetchAllLikedSongsFetchesPagesConcurrentlyInOrder(t *testing.T) {
+	const total = 230
+
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var items []string
+		for i := offset; i < total && i < offset+limit; i++ {
+			items = append(items, fmt.Sprintf(`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t%d","name":"Song","artists":[]}}`, i))
+		}
+		fmt.Fprintf(w, `{"items":[%s],"total":%d,"next":null}`, strings.Join(items, ","), total)
+	}))
+	defer srv.Close()
+
+	client := newTestClient()
+	client.apiURL = srv.URL
+	client.concurrency = 3
+
+	var reported []int
+	songs, err := client.FetchAllLikedSongs(context.Background(), func(processed, total int) {
+		reported = append(reported, processed)
+	})
+	if err != nil {
+		t.Fatalf("FetchAllLikedSongs: %v", err)
+	}
+
+	if len(songs) != total {
+		t.Fatalf("expected %d songs, got %d", total, len(songs))
+	}
+	for i, song := range songs {
+		if song.ID != fmt.Sprintf("t%d", i) {
+			t.Fatalf("expected songs in library order, got %s at %d", song.ID, i)
+		}
+	}
+
+	if got := maxInFlight.Load(); got != 3 {
+		t.Errorf("expected up to 3 pages in flight, got %d", got)
+	}
+	if len(reported) != 5 || reported[len(reported)-1] != total {
+		t.Errorf("expected progress after each of 5 pages ending at %d, got %v", total, reported)
+	}
+	for i := 1; i < len(reported); i++ {
+		if reported[i] <= reported[i-1] {
+			t.Errorf("expected progress to only go up, got %v", reported)
+		}
+	}
+}
+
+func TestFetchAllLikedSongsStopsAtFirstFailedPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "100" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"items":[`+strings.Repeat(`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t"}},`, 49)+`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t"}}],"total":500,"next":null}`)
+	}))
+	defer srv.Close()
+
+	client := newTestClient()
+	client.apiURL = srv.URL
+
+	if _, err := client.FetchAllLikedSongs(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the failed page's error, got %v", err)
+	}
+}
@@ -1,13 +1,13 @@
 package spotify
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
 type TokenResponse struct {
@@ -32,16 +32,16 @@ func (c *Config) GetAuthURL(state string) string {
 	params.Set("scope", strings.Join(Scopes, " "))
 	params.Set("state", state)
 
-	return fmt.Sprintf("%s?%s", AuthURL, params.Encode())
+	return fmt.Sprintf("%s?%s", c.AuthURL, params.Encode())
 }
 
-func (c *Config) ExchangeCode(code string) (*TokenResponse, error) {
+func (c *Config) ExchangeCode(ctx context.Context, code string) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
 	data.Set("redirect_uri", c.RedirectURI)
 
-	req, err := http.NewRequest("POST", TokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +50,7 @@ func (c *Config) ExchangeCode(code string) (*TokenResponse, error) {
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := NewClient("").Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -69,12 +68,12 @@ func (c *Config) ExchangeCode(code string) (*TokenResponse, error) {
 	return &token, nil
 }
 
-func (c *Config) RefreshAccessToken(refreshToken string) (*TokenResponse, error) {
+func (c *Config) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 
-	req, err := http.NewRequest("POST", TokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -83,8 +82,7 @@ func (c *Config) RefreshAccessToken(refreshToken string) (*TokenResponse, error)
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := NewClient("").Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -102,16 +100,13 @@ func (c *Config) RefreshAccessToken(refreshToken string) (*TokenResponse, error)
 	return &token, nil
 }
 
-func GetUserProfile(accessToken string) (*UserProfile, error) {
-	req, err := http.NewRequest("GET", APIURL+"/me", nil)
+func (c *Client) GetUserProfile(ctx context.Context) (*UserProfile, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+"/me", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
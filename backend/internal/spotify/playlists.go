@@ -1,13 +1,11 @@
 package spotify
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"time"
 )
 
 type Playlist struct {
@@ -15,6 +13,7 @@ type Playlist struct {
 	Name        string `json:"name"`
 	ExternalURL string `json:"external_urls"`
 	TracksTotal int    `json:"tracks_total"`
+	OwnerID     string `json:"owner_id"`
 }
 
 type PlaylistsResponse struct {
@@ -65,8 +64,8 @@ func ChunkTrackIDs(ids []string, chunkSize int) [][]string {
 	return chunks
 }
 
-func CreatePlaylist(accessToken, userID, name, description string) (*Playlist, error) {
-	url := fmt.Sprintf("%s/users/%s/playlists", APIURL, userID)
+func (c *Client) CreatePlaylist(ctx context.Context, userID, name, description string) (*Playlist, error) {
+	url := fmt.Sprintf("%s/users/%s/playlists", c.apiURL, userID)
 
 	body := createPlaylistRequest{
 		Name:        name,
@@ -74,21 +73,12 @@ func CreatePlaylist(accessToken, userID, name, description string) (*Playlist, e
 		Public:      false,
 	}
 
-	jsonBody, err := json.Marshal(body)
+	req, err := c.newRequest(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -118,118 +108,103 @@ func CreatePlaylist(accessToken, userID, name, description string) (*Playlist, e
 	}, nil
 }
 
-func AddTracksToPlaylist(accessToken, playlistID string, trackIDs []string) error {
-	uris := make([]string, len(trackIDs))
-	for i, id := range trackIDs {
-		uris[i] = "spotify:track:" + id
+// maxTracksPerRequest is how many tracks Spotify accepts in one add, replace
+// or remove request
+const maxTracksPerRequest = 100
+
+// AddTracksToPlaylist appends tracks to the playlist, 100 at a time, and
+// returns the playlist's snapshot_id after the last change
+func (c *Client) AddTracksToPlaylist(ctx context.Context, playlistID string, trackIDs []string) (string, error) {
+	var snapshotID string
+	for _, chunk := range ChunkTrackIDs(trackURIs(trackIDs), maxTracksPerRequest) {
+		var err error
+		snapshotID, err = c.writePlaylistTracks(ctx, "POST", playlistID, chunk)
+		if err != nil {
+			return "", fmt.Errorf("failed to add tracks: %w", err)
+		}
 	}
 
-	chunks := ChunkTrackIDs(uris, 100)
-
-	for _, chunk := range chunks {
-		url := fmt.Sprintf("%s/playlists/%s/tracks", APIURL, playlistID)
+	return snapshotID, nil
+}
 
-		body := addTracksRequest{URIs: chunk}
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
-			return err
-		}
+// ReplacePlaylistTracks makes trackIDs the playlist's only tracks, in order.
+// The first 100 replace the playlist's contents and the rest are appended.
+// It returns the playlist's snapshot_id after the last change.
+func (c *Client) ReplacePlaylistTracks(ctx context.Context, playlistID string, trackIDs []string) (string, error) {
+	chunks := ChunkTrackIDs(trackURIs(trackIDs), maxTracksPerRequest)
 
-		req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-		if err != nil {
-			return err
-		}
+	// An empty replace clears the playlist
+	first := []string{}
+	if len(chunks) > 0 {
+		first = chunks[0]
+	}
 
-		req.Header.Set("Authorization", "Bearer "+accessToken)
-		req.Header.Set("Content-Type", "application/json")
+	snapshotID, err := c.writePlaylistTracks(ctx, "PUT", playlistID, first)
+	if err != nil {
+		return "", fmt.Errorf("failed to replace tracks: %w", err)
+	}
 
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
+	for i := 1; i < len(chunks); i++ {
+		snapshotID, err = c.writePlaylistTracks(ctx, "POST", playlistID, chunks[i])
 		if err != nil {
-			return err
-		}
-		resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			return fmt.Errorf("failed to add tracks: %d", resp.StatusCode)
+			return "", fmt.Errorf("failed to add tracks after replacing: %w", err)
 		}
-
-		time.Sleep(100 * time.Millisecond)
 	}
 
-	return nil
+	return snapshotID, nil
 }
 
-func ClearPlaylist(accessToken, playlistID string) error {
-	url := fmt.Sprintf("%s/playlists/%s/tracks", APIURL, playlistID)
+// writePlaylistTracks sends one add (POST) or replace (PUT) request and
+// returns the resulting snapshot_id
+func (c *Client) writePlaylistTracks(ctx context.Context, method, playlistID string, uris []string) (string, error) {
+	url := fmt.Sprintf("%s/playlists/%s/tracks", c.apiURL, playlistID)
 
-	req, err := http.NewRequest("GET", url+"?limit=100", nil)
+	req, err := c.newRequest(ctx, method, url, addTracksRequest{URIs: uris})
 	if err != nil {
-		return err
+		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	var tracksResp struct {
-		Items []struct {
-			Track struct {
-				URI string `json:"uri"`
-			} `json:"track"`
-		} `json:"items"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tracksResp); err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%d - %s", resp.StatusCode, string(respBody))
 	}
 
-	if len(tracksResp.Items) == 0 {
-		return nil
+	var result struct {
+		SnapshotID string `json:"snapshot_id"`
 	}
-
-	tracks := make([]map[string]string, len(tracksResp.Items))
-	for i, item := range tracksResp.Items {
-		tracks[i] = map[string]string{"uri": item.Track.URI}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
 	}
+	return result.SnapshotID, nil
+}
 
-	deleteBody, _ := json.Marshal(map[string]interface{}{"tracks": tracks})
-
-	req, err = http.NewRequest("DELETE", url, bytes.NewReader(deleteBody))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err = client.Do(req)
-	if err != nil {
-		return err
+func trackURIs(trackIDs []string) []string {
+	uris := make([]string, len(trackIDs))
+	for i, id := range trackIDs {
+		uris[i] = "spotify:track:" + id
 	}
-	resp.Body.Close()
-
-	return nil
+	return uris
 }
 
-func GetUserPlaylists(accessToken string) ([]PlaylistItem, error) {
+func (c *Client) GetUserPlaylists(ctx context.Context) ([]PlaylistItem, error) {
 	var allPlaylists []PlaylistItem
 	offset := 0
 	limit := 50
 
 	for {
-		url := fmt.Sprintf("%s/me/playlists?limit=%d&offset=%d", APIURL, limit, offset)
-		req, err := http.NewRequest("GET", url, nil)
+		url := fmt.Sprintf("%s/me/playlists?limit=%d&offset=%d", c.apiURL, limit, offset)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return nil, err
 		}
-		req.Header.Set("Authorization", "Bearer "+accessToken)
 
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
+		resp, err := c.Do(req)
 		if err != nil {
 			return nil, err
 		}
@@ -246,8 +221,6 @@ func GetUserPlaylists(accessToken string) ([]PlaylistItem, error) {
 		}
 		resp.Body.Close()
 
-		log.Printf("DEBUG: Spotify reports total=%d, got %d items at offset %d", result.Total, len(result.Items), offset)
-
 		allPlaylists = append(allPlaylists, result.Items...)
 
 		if len(result.Items) < limit {
@@ -259,51 +232,145 @@ func GetUserPlaylists(accessToken string) ([]PlaylistItem, error) {
 	return allPlaylists, nil
 }
 
-func FindExistingPlaylist(accessToken, playlistName string) (*Playlist, error) {
-	url := fmt.Sprintf("%s/me/playlists?limit=50", APIURL)
+// FindExistingPlaylist returns the first playlist in the user's library that
+// ownerID owns and is called playlistName, or nil if there is none. Playlists
+// followed from other users are skipped since they can't be modified. To look
+// up several names, use a PlaylistLookup instead.
+func (c *Client) FindExistingPlaylist(ctx context.Context, ownerID, playlistName string) (*Playlist, error) {
+	return c.NewPlaylistLookup(ownerID).Find(ctx, playlistName)
+}
+
+// PlaylistLookup finds playlists owned by a user by name. The user's library
+// is listed on the first lookup and reused afterwards, so use one lookup per
+// run rather than refetching every page for each name.
+type PlaylistLookup struct {
+	client  *Client
+	ownerID string
+	byName  map[string]*Playlist
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) NewPlaylistLookup(ownerID string) *PlaylistLookup {
+	return &PlaylistLookup{client: c, ownerID: ownerID}
+}
+
+// Find returns the first owned playlist called name, or nil if there is none
+func (l *PlaylistLookup) Find(ctx context.Context, name string) (*Playlist, error) {
+	if l.byName == nil {
+		items, err := l.client.GetUserPlaylists(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		l.byName = make(map[string]*Playlist)
+		for _, p := range items {
+			if p.Owner.ID != l.ownerID {
+				continue
+			}
+			// Keep the first of several playlists with the same name
+			if _, seen := l.byName[p.Name]; !seen {
+				l.byName[p.Name] = &Playlist{
+					ID:          p.ID,
+					Name:        p.Name,
+					ExternalURL: p.ExternalURLs.Spotify,
+					TracksTotal: p.Tracks.Total,
+					OwnerID:     p.Owner.ID,
+				}
+			}
+		}
+	}
+
+	return l.byName[name], nil
+}
+
+// GetPlaylist fetches a playlist by ID, returning nil if it doesn't exist
+func (c *Client) GetPlaylist(ctx context.Context, playlistID string) (*Playlist, error) {
+	url := fmt.Sprintf("%s/playlists/%s?fields=id,name,external_urls,tracks.total,owner.id", c.apiURL, playlistID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var playlistsResp struct {
-		Items []struct {
-			ID           string `json:"id"`
-			Name         string `json:"name"`
-			ExternalURLs struct {
-				Spotify string `json:"spotify"`
-			} `json:"external_urls"`
-		} `json:"items"`
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get playlist: %d", resp.StatusCode)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&playlistsResp); err != nil {
+	var item PlaylistItem
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
 		return nil, err
 	}
 
-	for _, p := range playlistsResp.Items {
-		if p.Name == playlistName {
-			return &Playlist{
-				ID:          p.ID,
-				Name:        p.Name,
-				ExternalURL: p.ExternalURLs.Spotify,
-			}, nil
+	return &Playlist{
+		ID:          item.ID,
+		Name:        item.Name,
+		ExternalURL: item.ExternalURLs.Spotify,
+		TracksTotal: item.Tracks.Total,
+		OwnerID:     item.Owner.ID,
+	}, nil
+}
+
+// GetPlaylistTrackIDs returns the IDs of every track in a playlist, in order
+func (c *Client) GetPlaylistTrackIDs(ctx context.Context, playlistID string) ([]string, error) {
+	var trackIDs []string
+	url := fmt.Sprintf("%s/playlists/%s/tracks?limit=100&fields=items(track(id)),next", c.apiURL, playlistID)
+
+	for url != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to get playlist tracks: %d", resp.StatusCode)
+		}
+
+		var page struct {
+			Items []struct {
+				Track *struct {
+					ID string `json:"id"`
+				} `json:"track"`
+			} `json:"items"`
+			Next *string `json:"next"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			// Local files and removed tracks come back without an ID
+			if item.Track != nil && item.Track.ID != "" {
+				trackIDs = append(trackIDs, item.Track.ID)
+			}
+		}
+
+		url = ""
+		if page.Next != nil {
+			url = *page.Next
 		}
 	}
 
-	return nil, nil
+	return trackIDs, nil
 }
 
 // UpdatePlaylistDetails updates a playlist's name and/or description
-func UpdatePlaylistDetails(accessToken, playlistID, name, description string) error {
+func (c *Client) UpdatePlaylistDetails(ctx context.Context, playlistID, name, description string) error {
 	body := make(map[string]string)
 	if name != "" {
 		body["name"] = name
@@ -316,21 +383,12 @@ func UpdatePlaylistDetails(accessToken, playlistID, name, description string) er
 		return nil // Nothing to update
 	}
 
-	jsonBody, err := json.Marshal(body)
+	req, err := c.newRequest(ctx, "PUT", c.apiURL+"/playlists/"+playlistID, body)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("PUT", APIURL+"/playlists/"+playlistID, bytes.NewReader(jsonBody))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return err
 	}
@@ -343,16 +401,13 @@ func UpdatePlaylistDetails(accessToken, playlistID, name, description string) er
 }
 
 // UnfollowPlaylist removes a playlist from the user's library (unfollows it)
-func UnfollowPlaylist(accessToken, playlistID string) error {
-	req, err := http.NewRequest("DELETE", APIURL+"/playlists/"+playlistID+"/followers", nil)
+func (c *Client) UnfollowPlaylist(ctx context.Context, playlistID string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.apiURL+"/playlists/"+playlistID+"/followers", nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
 		return err
 	}
@@ -365,3 +420,74 @@ func UnfollowPlaylist(accessToken, playlistID string) error {
 	return nil
 }
 
+// IsFollowingPlaylist reports whether the current user still has the playlist
+// in their library. Deleting a playlist in Spotify only unfollows it, so a
+// deleted playlist can still be fetched by ID.
+func (c *Client) IsFollowingPlaylist(ctx context.Context, playlistID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+"/playlists/"+playlistID+"/followers/contains", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to check playlist follow: %d", resp.StatusCode)
+	}
+
+	var following []bool
+	if err := json.NewDecoder(resp.Body).Decode(&following); err != nil {
+		return false, err
+	}
+	return len(following) > 0 && following[0], nil
+}
+
+// RemoveTracksFromPlaylist removes every occurrence of the tracks from the
+// playlist, 100 at a time, and returns the playlist's snapshot_id after the
+// last change
+func (c *Client) RemoveTracksFromPlaylist(ctx context.Context, playlistID string, trackIDs []string) (string, error) {
+	url := fmt.Sprintf("%s/playlists/%s/tracks", c.apiURL, playlistID)
+	var snapshotID string
+
+	for _, chunk := range ChunkTrackIDs(trackURIs(trackIDs), maxTracksPerRequest) {
+		tracks := make([]map[string]string, len(chunk))
+		for i, uri := range chunk {
+			tracks[i] = map[string]string{"uri": uri}
+		}
+
+		req, err := c.newRequest(ctx, "DELETE", url, map[string]interface{}{"tracks": tracks})
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return "", fmt.Errorf("failed to remove tracks: %d - %s", resp.StatusCode, string(respBody))
+		}
+
+		var result struct {
+			SnapshotID string `json:"snapshot_id"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		snapshotID = result.SnapshotID
+	}
+
+	return snapshotID, nil
+}
@@ -1,6 +1,12 @@
 package spotify
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +48,45 @@ func TestChunkTrackIDs(t *testing.T) {
 		t.Errorf("expected second chunk to have 50 items, got %d", len(chunks[1]))
 	}
 }
+
+func TestReplacePlaylistTracks(t *testing.T) {
+	var requests []string
+	failAppend := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URIs []string `json:"uris"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, fmt.Sprintf("%s %d", r.Method, len(body.URIs)))
+
+		if r.Method == "POST" && failAppend {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"snapshot_id": "snap%d"}`, len(requests))
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	c.apiURL = srv.URL
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("t%d", i)
+	}
+
+	snapshot, err := c.ReplacePlaylistTracks(context.Background(), "p1", ids[:150])
+	if err != nil {
+		t.Fatalf("ReplacePlaylistTracks: %v", err)
+	}
+	if snapshot != "snap2" || strings.Join(requests, ",") != "PUT 100,POST 50" {
+		t.Errorf("expected PUT 100 then POST 50 ending at snap2, got %v / %s", requests, snapshot)
+	}
+
+	// A failed append must surface as an error
+	failAppend = true
+	if _, err := c.ReplacePlaylistTracks(context.Background(), "p1", ids); err == nil {
+		t.Error("expected an error when appending fails")
+	}
+}
@@ -0,0 +1,62 @@
+package spotify
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultLimiter paces all outgoing Spotify requests from this process.
+// Spotify rate limits per application, so the budget is shared between users.
+var defaultLimiter = newRateLimiter(10, 10)
+
+// rateLimiter is a GCRA-style limiter allowing `perSecond` requests on
+// average with bursts of up to `burst` requests
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tat      time.Time // theoretical arrival time of the next request
+}
+
+func newRateLimiter(perSecond, burst int) *rateLimiter {
+	if perSecond < 1 {
+		perSecond = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: time.Second / time.Duration(perSecond),
+		burst:    burst,
+	}
+}
+
+// reserve claims the next request slot and returns how long the caller must
+// wait before sending it
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.tat.Before(now) {
+		l.tat = now
+	}
+	l.tat = l.tat.Add(l.interval)
+
+	delay := l.tat.Sub(now) - time.Duration(l.burst)*l.interval
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// pause holds back every request for at least d, e.g. after a 429
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	resume := time.Now().Add(d + time.Duration(l.burst-1)*l.interval)
+	if l.tat.Before(resume) {
+		l.tat = resume
+	}
+}
@@ -0,0 +1,789 @@
+// Package spotifytest provides an in-process fake of the Spotify accounts
+// service and Web API for offline tests.
+//
+//	srv := spotifytest.NewServer()
+//	defer srv.Close()
+//	token := srv.AddUser("alice")
+//	srv.SetArtistGenres("artist1", "indie rock")
+//	srv.AddLikedSongs("alice", spotify.Song{ID: "t1", Artists: []spotify.Artist{{ID: "artist1"}}})
+//	client := srv.Config().NewClient(token)
+package spotifytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spotify-genre-organizer/backend/internal/spotify"
+)
+
+// Playlist is the fake server's view of a playlist
+type Playlist struct {
+	ID          string
+	Name        string
+	Description string
+	OwnerID     string
+	SnapshotID  string
+	Tracks      []PlaylistTrack
+}
+
+// PlaylistTrack is a track entry within a playlist
+type PlaylistTrack struct {
+	ID      string
+	AddedAt time.Time
+}
+
+// TrackIDs returns the IDs of the playlist's tracks in order
+func (p Playlist) TrackIDs() []string {
+	ids := make([]string, len(p.Tracks))
+	for i, t := range p.Tracks {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+type user struct {
+	id         string
+	likedSongs []spotify.Song
+	followed   []string // playlist IDs in library order
+}
+
+// Server is a fake Spotify backed by httptest.Server. All state is guarded by
+// a mutex so it can be seeded and inspected while requests are in flight.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	users         map[string]*user
+	tokens        map[string]string // access token -> user ID
+	refreshTokens map[string]string // refresh token -> user ID
+	codes         map[string]string // authorization code -> user ID
+	artists       map[string][]string
+	playlists     map[string]*Playlist
+	nextID        int
+	requests      []string // "METHOD /path" of each API request
+	failures      []int
+}
+
+// NewServer starts a fake Spotify server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		users:         make(map[string]*user),
+		tokens:        make(map[string]string),
+		refreshTokens: make(map[string]string),
+		codes:         make(map[string]string),
+		artists:       make(map[string][]string),
+		playlists:     make(map[string]*Playlist),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/token", s.handleToken)
+	mux.HandleFunc("GET /v1/me", s.authed(s.handleMe))
+	mux.HandleFunc("GET /v1/me/tracks", s.authed(s.handleLikedSongs))
+	mux.HandleFunc("GET /v1/artists", s.authed(s.handleArtists))
+	mux.HandleFunc("GET /v1/me/playlists", s.authed(s.handleMyPlaylists))
+	mux.HandleFunc("POST /v1/users/{id}/playlists", s.authed(s.handleCreatePlaylist))
+	mux.HandleFunc("GET /v1/playlists/{id}", s.authed(s.handleGetPlaylist))
+	mux.HandleFunc("PUT /v1/playlists/{id}", s.authed(s.handleUpdatePlaylist))
+	mux.HandleFunc("GET /v1/playlists/{id}/tracks", s.authed(s.handleGetTracks))
+	mux.HandleFunc("POST /v1/playlists/{id}/tracks", s.authed(s.handleAddTracks))
+	mux.HandleFunc("PUT /v1/playlists/{id}/tracks", s.authed(s.handleReplaceTracks))
+	mux.HandleFunc("DELETE /v1/playlists/{id}/tracks", s.authed(s.handleRemoveTracks))
+	mux.HandleFunc("GET /v1/playlists/{id}/followers/contains", s.authed(s.handleFollowsContains))
+	mux.HandleFunc("DELETE /v1/playlists/{id}/followers", s.authed(s.handleUnfollow))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns a spotify.Config whose endpoints point at this server
+func (s *Server) Config() *spotify.Config {
+	return &spotify.Config{
+		ClientID:     "test-client-id",
+		ClientSecret: "test-client-secret",
+		RedirectURI:  "http://localhost/callback",
+		AuthURL:      s.URL + "/authorize",
+		TokenURL:     s.URL + "/api/token",
+		APIURL:       s.URL + "/v1",
+	}
+}
+
+// AddUser registers a user and returns an access token for them
+func (s *Server) AddUser(userID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		s.users[userID] = &user{id: userID}
+	}
+	token := s.newIDLocked("token")
+	s.tokens[token] = userID
+	return token
+}
+
+// AddAuthCode registers an authorization code that exchanges into tokens for
+// userID
+func (s *Server) AddAuthCode(code, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		s.users[userID] = &user{id: userID}
+	}
+	s.codes[code] = userID
+}
+
+// RevokeToken invalidates an access token, as if it had expired
+func (s *Server) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+}
+
+// AddLikedSongs adds songs to the user's library. Songs with a zero AddedAt
+// are stamped with the current time. /me/tracks returns newest first.
+func (s *Server) AddLikedSongs(userID string, songs ...spotify.Song) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.users[userID]
+	if u == nil {
+		u = &user{id: userID}
+		s.users[userID] = u
+	}
+	for _, song := range songs {
+		if song.AddedAt.IsZero() {
+			song.AddedAt = time.Now()
+		}
+		u.likedSongs = append(u.likedSongs, song)
+	}
+	sort.SliceStable(u.likedSongs, func(i, j int) bool {
+		return u.likedSongs[i].AddedAt.After(u.likedSongs[j].AddedAt)
+	})
+}
+
+// RemoveLikedSong removes a song from the user's library
+func (s *Server) RemoveLikedSong(userID, trackID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.users[userID]
+	if u == nil {
+		return
+	}
+	for i, song := range u.likedSongs {
+		if song.ID == trackID {
+			u.likedSongs = append(u.likedSongs[:i], u.likedSongs[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetArtistGenres sets the genres /artists reports for an artist
+func (s *Server) SetArtistGenres(artistID string, genres ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artists[artistID] = genres
+}
+
+// AddPlaylist creates a playlist owned by ownerID with the given tracks and
+// returns its ID
+func (s *Server) AddPlaylist(ownerID, name string, trackIDs ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.createPlaylistLocked(ownerID, name, "")
+	now := time.Now()
+	for _, id := range trackIDs {
+		p.Tracks = append(p.Tracks, PlaylistTrack{ID: id, AddedAt: now})
+	}
+	return p.ID
+}
+
+// FollowPlaylist adds someone else's playlist to the user's library
+func (s *Server) FollowPlaylist(userID, playlistID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.users[userID]; u != nil {
+		u.followed = append(u.followed, playlistID)
+	}
+}
+
+// Playlist returns a copy of the playlist with the given ID
+func (s *Server) Playlist(id string) (Playlist, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.playlists[id]
+	if !ok {
+		return Playlist{}, false
+	}
+	cp := *p
+	cp.Tracks = append([]PlaylistTrack(nil), p.Tracks...)
+	return cp, true
+}
+
+// Playlists returns copies of the playlists in the user's library
+func (s *Server) Playlists(userID string) []Playlist {
+	s.mu.Lock()
+	u := s.users[userID]
+	var ids []string
+	if u != nil {
+		ids = append(ids, u.followed...)
+	}
+	s.mu.Unlock()
+
+	var result []Playlist
+	for _, id := range ids {
+		if p, ok := s.Playlist(id); ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// FailNext makes the next n API requests fail with status. 429 responses
+// carry "Retry-After: 0".
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// RequestCount returns the number of API requests served so far
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// Requests returns the API requests served so far as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newIDLocked(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s%d", prefix, s.nextID)
+}
+
+func (s *Server) createPlaylistLocked(ownerID, name, description string) *Playlist {
+	p := &Playlist{
+		ID:          s.newIDLocked("playlist"),
+		Name:        name,
+		Description: description,
+		OwnerID:     ownerID,
+	}
+	p.SnapshotID = s.newIDLocked("snapshot")
+	s.playlists[p.ID] = p
+
+	if u := s.users[ownerID]; u != nil {
+		// New playlists appear at the top of the user's library
+		u.followed = append([]string{p.ID}, u.followed...)
+	}
+	return p
+}
+
+type authedHandler func(w http.ResponseWriter, r *http.Request, u *user)
+
+// authed resolves the bearer token to a user and applies injected failures
+func (s *Server) authed(next authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		if len(s.failures) > 0 {
+			status := s.failures[0]
+			s.failures = s.failures[1:]
+			s.mu.Unlock()
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			writeError(w, status, "injected failure")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		userID, ok := s.tokens[token]
+		u := s.users[userID]
+		s.mu.Unlock()
+
+		if !ok || u == nil {
+			writeError(w, http.StatusUnauthorized, "The access token expired")
+			return
+		}
+		next(w, r, u)
+	}
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var userID string
+	var ok bool
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		userID, ok = s.codes[r.PostForm.Get("code")]
+		delete(s.codes, r.PostForm.Get("code"))
+	case "refresh_token":
+		userID, ok = s.refreshTokens[r.PostForm.Get("refresh_token")]
+	}
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	access := s.newIDLocked("token")
+	s.tokens[access] = userID
+	resp := spotify.TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		Scope:       strings.Join(spotify.Scopes, " "),
+	}
+	if r.PostForm.Get("grant_type") == "authorization_code" {
+		resp.RefreshToken = s.newIDLocked("refresh")
+		s.refreshTokens[resp.RefreshToken] = userID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request, u *user) {
+	writeJSON(w, http.StatusOK, spotify.UserProfile{
+		ID:          u.id,
+		DisplayName: u.id,
+		Email:       u.id + "@example.com",
+	})
+}
+
+type artistJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type trackJSON struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	URI     string       `json:"uri"`
+	Artists []artistJSON `json:"artists"`
+}
+
+type savedTrackJSON struct {
+	AddedAt string    `json:"added_at"`
+	Track   trackJSON `json:"track"`
+}
+
+func (s *Server) handleLikedSongs(w http.ResponseWriter, r *http.Request, u *user) {
+	limit, offset := pageParams(r, 20, 50)
+
+	s.mu.Lock()
+	songs := u.likedSongs
+	total := len(songs)
+	var items []savedTrackJSON
+	for i := offset; i < total && i < offset+limit; i++ {
+		song := songs[i]
+		artists := make([]artistJSON, len(song.Artists))
+		for j, a := range song.Artists {
+			artists[j] = artistJSON{ID: a.ID, Name: a.Name}
+		}
+		items = append(items, savedTrackJSON{
+			AddedAt: song.AddedAt.UTC().Format(time.RFC3339),
+			Track: trackJSON{
+				ID:      song.ID,
+				Name:    song.Name,
+				URI:     "spotify:track:" + song.ID,
+				Artists: artists,
+			},
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, page(r, items, total, limit, offset))
+}
+
+func (s *Server) handleArtists(w http.ResponseWriter, r *http.Request, u *user) {
+	ids := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(ids) > 50 {
+		writeError(w, http.StatusBadRequest, "too many ids requested")
+		return
+	}
+
+	s.mu.Lock()
+	artists := make([]interface{}, len(ids))
+	for i, id := range ids {
+		genres, ok := s.artists[id]
+		if !ok {
+			// Spotify returns null for unknown artists; unseeded ones have no genres
+			genres = []string{}
+		}
+		artists[i] = spotify.ArtistDetails{ID: id, Name: id, Genres: genres}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"artists": artists})
+}
+
+type playlistJSON struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	SnapshotID   string `json:"snapshot_id"`
+	ExternalURLs struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+	Images []struct {
+		URL string `json:"url"`
+	} `json:"images"`
+	Tracks struct {
+		Total int `json:"total"`
+	} `json:"tracks"`
+	Owner struct {
+		ID string `json:"id"`
+	} `json:"owner"`
+}
+
+func toPlaylistJSON(p *Playlist) playlistJSON {
+	var pj playlistJSON
+	pj.ID = p.ID
+	pj.Name = p.Name
+	pj.Description = p.Description
+	pj.SnapshotID = p.SnapshotID
+	pj.ExternalURLs.Spotify = "https://open.spotify.com/playlist/" + p.ID
+	pj.Tracks.Total = len(p.Tracks)
+	pj.Owner.ID = p.OwnerID
+	return pj
+}
+
+func (s *Server) handleMyPlaylists(w http.ResponseWriter, r *http.Request, u *user) {
+	limit, offset := pageParams(r, 20, 50)
+
+	s.mu.Lock()
+	var items []playlistJSON
+	total := len(u.followed)
+	for i := offset; i < total && i < offset+limit; i++ {
+		if p, ok := s.playlists[u.followed[i]]; ok {
+			items = append(items, toPlaylistJSON(p))
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, page(r, items, total, limit, offset))
+}
+
+func (s *Server) handleCreatePlaylist(w http.ResponseWriter, r *http.Request, u *user) {
+	if r.PathValue("id") != u.id {
+		writeError(w, http.StatusForbidden, "You cannot create a playlist for another user")
+		return
+	}
+
+	var body struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		writeError(w, http.StatusBadRequest, "Missing required field: name")
+		return
+	}
+
+	s.mu.Lock()
+	p := s.createPlaylistLocked(u.id, body.Name, body.Description)
+	resp := toPlaylistJSON(p)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// ownedPlaylistLocked looks up a playlist the user may modify, writing an error
+// response and returning nil otherwise. Caller must hold s.mu.
+func (s *Server) ownedPlaylistLocked(w http.ResponseWriter, r *http.Request, u *user) *Playlist {
+	p, ok := s.playlists[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found.")
+		return nil
+	}
+	if p.OwnerID != u.id {
+		writeError(w, http.StatusForbidden, "You cannot modify a playlist you don't own")
+		return nil
+	}
+	return p
+}
+
+func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request, u *user) {
+	s.mu.Lock()
+	p, ok := s.playlists[r.PathValue("id")]
+	var resp playlistJSON
+	if ok {
+		resp = toPlaylistJSON(p)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleUpdatePlaylist(w http.ResponseWriter, r *http.Request, u *user) {
+	var body struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.ownedPlaylistLocked(w, r, u)
+	if p == nil {
+		return
+	}
+	if body.Name != nil {
+		p.Name = *body.Name
+	}
+	if body.Description != nil {
+		p.Description = *body.Description
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type playlistTrackJSON struct {
+	AddedAt string    `json:"added_at"`
+	Track   trackJSON `json:"track"`
+}
+
+func (s *Server) handleGetTracks(w http.ResponseWriter, r *http.Request, u *user) {
+	limit, offset := pageParams(r, 100, 100)
+
+	s.mu.Lock()
+	p, ok := s.playlists[r.PathValue("id")]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	total := len(p.Tracks)
+	var items []playlistTrackJSON
+	for i := offset; i < total && i < offset+limit; i++ {
+		t := p.Tracks[i]
+		items = append(items, playlistTrackJSON{
+			AddedAt: t.AddedAt.UTC().Format(time.RFC3339),
+			Track:   trackJSON{ID: t.ID, URI: "spotify:track:" + t.ID},
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, page(r, items, total, limit, offset))
+}
+
+func (s *Server) handleAddTracks(w http.ResponseWriter, r *http.Request, u *user) {
+	var body struct {
+		URIs     []string `json:"uris"`
+		Position *int     `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body.URIs) > 100 {
+		writeError(w, http.StatusBadRequest, "You can add a maximum of 100 tracks per request.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.ownedPlaylistLocked(w, r, u)
+	if p == nil {
+		return
+	}
+
+	added := tracksFromURIs(body.URIs)
+	pos := len(p.Tracks)
+	if body.Position != nil && *body.Position >= 0 && *body.Position < pos {
+		pos = *body.Position
+	}
+	p.Tracks = append(p.Tracks[:pos], append(added, p.Tracks[pos:]...)...)
+	p.SnapshotID = s.newIDLocked("snapshot")
+
+	writeJSON(w, http.StatusCreated, map[string]string{"snapshot_id": p.SnapshotID})
+}
+
+func (s *Server) handleReplaceTracks(w http.ResponseWriter, r *http.Request, u *user) {
+	var body struct {
+		URIs []string `json:"uris"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body.URIs) > 100 {
+		writeError(w, http.StatusBadRequest, "You can replace a maximum of 100 tracks per request.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.ownedPlaylistLocked(w, r, u)
+	if p == nil {
+		return
+	}
+	p.Tracks = tracksFromURIs(body.URIs)
+	p.SnapshotID = s.newIDLocked("snapshot")
+
+	writeJSON(w, http.StatusOK, map[string]string{"snapshot_id": p.SnapshotID})
+}
+
+func (s *Server) handleRemoveTracks(w http.ResponseWriter, r *http.Request, u *user) {
+	var body struct {
+		Tracks []struct {
+			URI string `json:"uri"`
+		} `json:"tracks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body.Tracks) > 100 {
+		writeError(w, http.StatusBadRequest, "You can remove a maximum of 100 tracks per request.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.ownedPlaylistLocked(w, r, u)
+	if p == nil {
+		return
+	}
+
+	remove := make(map[string]bool)
+	for _, t := range body.Tracks {
+		remove[strings.TrimPrefix(t.URI, "spotify:track:")] = true
+	}
+	kept := p.Tracks[:0]
+	for _, t := range p.Tracks {
+		if !remove[t.ID] {
+			kept = append(kept, t)
+		}
+	}
+	p.Tracks = kept
+	p.SnapshotID = s.newIDLocked("snapshot")
+
+	writeJSON(w, http.StatusOK, map[string]string{"snapshot_id": p.SnapshotID})
+}
+
+func (s *Server) handleUnfollow(w http.ResponseWriter, r *http.Request, u *user) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	for i, followed := range u.followed {
+		if followed == id {
+			u.followed = append(u.followed[:i], u.followed[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleFollowsContains(w http.ResponseWriter, r *http.Request, u *user) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.playlists[id]; !ok {
+		writeError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	following := false
+	for _, followed := range u.followed {
+		if followed == id {
+			following = true
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, []bool{following})
+}
+
+func tracksFromURIs(uris []string) []PlaylistTrack {
+	now := time.Now()
+	tracks := make([]PlaylistTrack, len(uris))
+	for i, uri := range uris {
+		tracks[i] = PlaylistTrack{ID: strings.TrimPrefix(uri, "spotify:track:"), AddedAt: now}
+	}
+	return tracks
+}
+
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// page wraps items in Spotify's paging object, including an absolute next URL
+func page[T any](r *http.Request, items []T, total, limit, offset int) map[string]interface{} {
+	if items == nil {
+		items = []T{}
+	}
+	var next *string
+	if offset+limit < total {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset+limit))
+		u := fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, q.Encode())
+		next = &u
+	}
+	return map[string]interface{}{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+		"next":   next,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"status":  status,
+			"message": message,
+		},
+	})
+}
@@ -6,7 +6,7 @@ import { VinylIcon } from '@/components/VinylIcon';
 import { Button } from '@/components/Button';
 import { Slider } from '@/components/Slider';
 import { useUser } from '@/hooks/useUser';
-import { startOrganize, logout, getLibraryCount, getSyncStatus } from '@/lib/api';
+import { startOrganize, logout, getLibraryCount, getSyncStatus, getOrganizeHistory, OrganizeJobSummary } from '@/lib/api';
 
 const LIBRARY_COUNT_CACHE_KEY = 'spotify_library_count';
 
@@ -19,6 +19,7 @@ export default function Dashboard() {
   const [likedSongsCount, setLikedSongsCount] = useState<number | null>(null);
   const [countLoading, setCountLoading] = useState(true);
   const [newSongsCount, setNewSongsCount] = useState(0);
+  const [history, setHistory] = useState<OrganizeJobSummary[]>([]);
 
   useEffect(() => {
     // Check localStorage first for instant display
@@ -58,14 +59,26 @@ export default function Dashboard() {
     };
 
     fetchSyncStatus();
+
+    // Fetch previous organize runs
+    const fetchHistory = async () => {
+      try {
+        const data = await getOrganizeHistory();
+        setHistory(data.jobs.slice(0, 5));
+      } catch (error) {
+        console.error('Failed to fetch organize history:', error);
+      }
+    };
+
+    fetchHistory();
   }, []);
 
   const songsPerPlaylist = likedSongsCount ? Math.round(likedSongsCount / playlistCount) : 0;
 
-  const handleOrganize = async () => {
+  const handleOrganize = async (dryRun = false) => {
     setIsOrganizing(true);
     try {
-      const { job_id } = await startOrganize(playlistCount, replaceExisting);
+      const { job_id } = await startOrganize(playlistCount, replaceExisting, dryRun);
       router.push(`/processing?job=${job_id}`);
     } catch (error) {
       console.error('Failed to start organize:', error);
@@ -213,7 +226,7 @@ export default function Dashboard() {
         <Button
           size="lg"
           className="w-full flex items-center justify-center gap-2"
-          onClick={handleOrganize}
+          onClick={() => handleOrganize()}
           disabled={isOrganizing}
         >
           {isOrganizing ? (
@@ -228,7 +241,38 @@ export default function Dashboard() {
             </>
           )}
         </Button>
+        <button
+          onClick={() => handleOrganize(true)}
+          disabled={isOrganizing}
+          className="w-full mt-3 text-sm text-text-muted hover:text-text-cream underline disabled:opacity-50"
+        >
+          Preview without changing anything
+        </button>
       </div>
+
+      {/* Recent Runs */}
+      {history.length > 0 && (
+        <div className="w-full max-w-xl mt-8">
+          <h2 className="font-display text-lg text-text-cream mb-3">Recent runs</h2>
+          <ul className="space-y-2">
+            {history.map((job) => (
+              <li key={job.id} className="flex items-center justify-between text-sm">
+                <span className="text-text-muted">
+                  {new Date(job.created_at).toLocaleString()} &middot; {job.playlist_count} playlists
+                  {job.replace_existing ? ' (update)' : ' (fresh)'}
+                </span>
+                <span className={job.status === 'completed' ? 'text-text-cream' : 'text-text-muted'}>
+                  {job.status === 'completed'
+                    ? job.dry_run
+                      ? 'preview'
+                      : `${job.result?.playlists.length ?? 0} created`
+                    : job.status}
+                </span>
+              </li>
+            ))}
+          </ul>
+        </div>
+      )}
     </main>
   );
 }
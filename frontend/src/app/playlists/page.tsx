@@ -13,6 +13,7 @@ import {
     getSyncStatus,
     syncAllPlaylists,
     ManagedPlaylist,
+    SyncPolicy,
     SyncStatus,
     ApiError,
 } from '@/lib/api';
@@ -79,7 +80,7 @@ export default function Playlists() {
             const result = await syncAllPlaylists();
 
             // Build success message based on result
-            let message = `${result.playlists_updated} crates synced • ${result.total_songs} songs`;
+            let message = `${result.playlists_updated} crates synced • +${result.tracks_added} / -${result.tracks_removed} songs`;
             if (result.failed_playlists && result.failed_playlists.length > 0) {
                 message += ` (${result.failed_playlists.length} failed)`;
             }
@@ -110,7 +111,11 @@ export default function Playlists() {
                     p.spotify_id === id ? { ...p, song_count: result.song_count } : p
                 )
             );
-            showToast(`Added ${result.song_count} songs to ${playlist?.genre || 'playlist'}`, 'success');
+            let message = `${playlist?.genre || 'Playlist'}: +${result.added.length} / -${result.removed.length} songs`;
+            if (result.user_owned.length > 0) {
+                message += ` • kept ${result.user_owned.length} you added`;
+            }
+            showToast(message, 'success');
             loadSyncStatus(); // Refresh sync status
         } catch (err) {
             showToast(`Couldn't sync ${playlist?.genre || 'playlist'} — Retry`, 'error', {
@@ -156,6 +161,20 @@ export default function Playlists() {
         }
     };
 
+    const changeSyncPolicy = async (id: string, syncPolicy: SyncPolicy) => {
+        try {
+            await updatePlaylist(id, undefined, undefined, syncPolicy);
+            setPlaylists((prev) =>
+                prev.map((p) =>
+                    p.spotify_id === id ? { ...p, sync_policy: syncPolicy } : p
+                )
+            );
+        } catch (err) {
+            console.error(err);
+            showToast('Failed to change sync policy', 'error');
+        }
+    };
+
     const toggleExpand = (id: string) => {
         if (expandedId === id) {
             setExpandedId(null);
@@ -338,7 +357,7 @@ export default function Playlists() {
                                                         size="sm"
                                                         variant="secondary"
                                                         onClick={() => handleRefresh(playlist.spotify_id)}
-                                                        disabled={isRefreshing}
+                                                        disabled={isRefreshing || playlist.sync_policy === 'frozen'}
                                                     >
                                                         {isRefreshing ? 'Syncing...' : '↻ Refresh'}
                                                     </Button>
@@ -361,6 +380,17 @@ export default function Playlists() {
                                                         Open in Spotify ↗
                                                     </a>
 
+                                                    <select
+                                                        value={playlist.sync_policy || 'mirror'}
+                                                        onChange={(e) => changeSyncPolicy(playlist.spotify_id, e.target.value as SyncPolicy)}
+                                                        className="bg-gray-700 text-text-cream text-sm font-medium rounded-full px-4 py-2 outline-none"
+                                                        title="How syncing treats this crate"
+                                                    >
+                                                        <option value="mirror">Mirror genre</option>
+                                                        <option value="append_only">Only add songs</option>
+                                                        <option value="frozen">Frozen</option>
+                                                    </select>
+
                                                     <div className="flex-grow" />
 
                                                     <button
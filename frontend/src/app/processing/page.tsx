@@ -3,9 +3,25 @@
 import { useEffect, useState, Suspense } from 'react';
 import { useRouter, useSearchParams } from 'next/navigation';
 import { VinylIcon } from '@/components/VinylIcon';
+import { Button } from '@/components/Button';
 import { ProgressBar } from '@/components/ProgressBar';
 import { GenreTag } from '@/components/GenreTag';
-import { getOrganizeStatus } from '@/lib/api';
+import {
+  applyOrganizePlan,
+  cancelOrganize,
+  editOrganizePlan,
+  getOrganizeStatus,
+  subscribeOrganizeEvents,
+  OrganizePlan,
+} from '@/lib/api';
+
+interface CreatedPlaylist {
+  name: string;
+  genre: string;
+  spotify_id: string;
+  spotify_url: string;
+  song_count: number;
+}
 
 interface JobStatus {
   id: string;
@@ -15,14 +31,11 @@ interface JobStatus {
   total_songs: number;
   genres_discovered: string[];
   result?: {
-    playlists: Array<{
-      name: string;
-      genre: string;
-      spotify_id: string;
-      spotify_url: string;
-      song_count: number;
-    }>;
+    playlists: CreatedPlaylist[];
   };
+  dry_run?: boolean;
+  plan_id?: string;
+  preview?: OrganizePlan;
   error?: string;
 }
 
@@ -33,6 +46,9 @@ function ProcessingContent() {
 
   const [status, setStatus] = useState<JobStatus | null>(null);
   const [tonearmAngle, setTonearmAngle] = useState(0);
+  const [cancelling, setCancelling] = useState(false);
+  const [planError, setPlanError] = useState<string | null>(null);
+  const [applying, setApplying] = useState(false);
 
   useEffect(() => {
     if (!jobId) {
@@ -40,40 +56,147 @@ function ProcessingContent() {
       return;
     }
 
+    let stopped = false;
+    let pollTimer: ReturnType<typeof setTimeout> | undefined;
+
+    const applyStatus = (data: JobStatus) => {
+      setStatus(data);
+
+      if (data.total_songs > 0) {
+        const progress = data.songs_processed / data.total_songs;
+        setTonearmAngle(progress * 30);
+      }
+
+      if (data.status === 'completed' && !data.dry_run) {
+        router.push(`/success?job=${jobId}`);
+      } else if (data.status === 'failed') {
+        console.error('Job failed:', data.error);
+      } else if (data.status === 'cancelled') {
+        setCancelling(false);
+      }
+    };
+
+    const updateStatus = (update: (prev: JobStatus) => JobStatus) => {
+      setStatus((prev) => (prev ? update(prev) : prev));
+    };
+
+    // Polling is only used when the event stream can't be established
     const pollStatus = async () => {
+      if (stopped) return;
       try {
         const data = await getOrganizeStatus(jobId);
-        setStatus(data);
-
-        if (data.total_songs > 0) {
-          const progress = data.songs_processed / data.total_songs;
-          setTonearmAngle(progress * 30);
-        }
-
-        if (data.status === 'completed') {
-          router.push(`/success?job=${jobId}`);
-        } else if (data.status === 'failed') {
-          console.error('Job failed:', data.error);
-        } else {
-          setTimeout(pollStatus, 1000);
-        }
+        applyStatus(data);
+        if (['completed', 'failed', 'cancelled'].includes(data.status)) return;
+        pollTimer = setTimeout(pollStatus, 1000);
       } catch (error) {
         console.error('Failed to get status:', error);
-        setTimeout(pollStatus, 2000);
+        pollTimer = setTimeout(pollStatus, 2000);
+      }
+    };
+
+    const events = subscribeOrganizeEvents(jobId);
+
+    events.addEventListener('status', (e) => {
+      applyStatus(JSON.parse((e as MessageEvent).data));
+    });
+    events.addEventListener('stage', (e) => {
+      const { stage } = JSON.parse((e as MessageEvent).data);
+      updateStatus((prev) => ({ ...prev, stage }));
+    });
+    events.addEventListener('progress', (e) => {
+      const { songs_processed, total_songs } = JSON.parse((e as MessageEvent).data);
+      updateStatus((prev) => ({ ...prev, songs_processed, total_songs }));
+      if (total_songs > 0) {
+        setTonearmAngle((songs_processed / total_songs) * 30);
+      }
+    });
+    events.addEventListener('genres', (e) => {
+      const { genres_discovered } = JSON.parse((e as MessageEvent).data);
+      updateStatus((prev) => ({ ...prev, genres_discovered }));
+    });
+    events.addEventListener('playlist', (e) => {
+      const playlist: CreatedPlaylist = JSON.parse((e as MessageEvent).data);
+      updateStatus((prev) => ({
+        ...prev,
+        result: { playlists: [...(prev.result?.playlists ?? []), playlist] },
+      }));
+    });
+    events.addEventListener('done', (e) => {
+      events.close();
+      applyStatus(JSON.parse((e as MessageEvent).data));
+    });
+    events.onerror = () => {
+      // EventSource reconnects on its own, but fall back to polling if the
+      // stream is unavailable (e.g. buffered by a proxy)
+      if (events.readyState === EventSource.CLOSED) {
+        pollStatus();
       }
     };
 
-    pollStatus();
+    return () => {
+      stopped = true;
+      events.close();
+      clearTimeout(pollTimer);
+    };
   }, [jobId, router]);
 
+  const handleCancel = async () => {
+    if (!jobId) return;
+    setCancelling(true);
+    try {
+      const data = await cancelOrganize(jobId);
+      if (data.status === 'cancelled') {
+        setStatus(data);
+        setCancelling(false);
+      }
+    } catch (error) {
+      console.error('Failed to cancel:', error);
+      setCancelling(false);
+    }
+  };
+
+  const dropGenre = async (genre: string) => {
+    if (!status?.preview) return;
+    setPlanError(null);
+    try {
+      const plan = await editOrganizePlan(status.preview.id, [{ op: 'drop_genre', genre }]);
+      setStatus({ ...status, preview: plan });
+    } catch (error) {
+      setPlanError(error instanceof Error ? error.message : 'Failed to edit plan');
+    }
+  };
+
+  const applyPlan = async () => {
+    if (!status?.preview) return;
+    setPlanError(null);
+    setApplying(true);
+    try {
+      const { job_id } = await applyOrganizePlan(status.preview.id);
+      router.push(`/processing?job=${job_id}`);
+    } catch (error) {
+      setPlanError(error instanceof Error ? error.message : 'Failed to apply plan');
+      setApplying(false);
+    }
+  };
+
   const getStageText = (stage: string) => {
+    if (status?.status === 'cancelled') {
+      return 'Organizing cancelled';
+    }
+    if (status?.dry_run && status.status === 'completed') {
+      return 'Here is what would be created';
+    }
     switch (stage) {
+      case 'queued':
+        return 'Waiting for a free slot...';
       case 'fetching':
         return 'Analyzing your library...';
       case 'analyzing':
         return 'Detecting genres...';
       case 'creating':
         return 'Creating playlists...';
+      case 'planning':
+        return 'Planning playlists...';
       default:
         return 'Processing...';
     }
@@ -116,6 +239,96 @@ function ProcessingContent() {
         />
       </div>
 
+      {/* Dry-run preview */}
+      {status?.dry_run && status.preview && (
+        <div className="w-full max-w-md mb-8">
+          <ul className="text-text-cream space-y-1">
+            {status.preview.playlists.map((p) => (
+              <li key={p.genre} className="flex justify-between gap-4">
+                <span>{p.name}</span>
+                <span className="text-text-muted">
+                  {p.tracks.length} songs
+                  {status.preview?.status === 'draft' && (
+                    <button
+                      onClick={() => dropGenre(p.genre)}
+                      className="ml-3 hover:text-text-cream underline"
+                    >
+                      Drop
+                    </button>
+                  )}
+                </span>
+              </li>
+            ))}
+          </ul>
+          {status.preview.merged_into_other.length > 0 && (
+            <p className="text-text-muted text-sm mt-4">
+              Merged into Other:{' '}
+              {status.preview.merged_into_other.map((g) => `${g.genre} (${g.song_count})`).join(', ')}
+            </p>
+          )}
+          {status.preview.unplaced_tracks > 0 && (
+            <p className="text-text-muted text-sm mt-2">
+              {status.preview.unplaced_tracks} songs would not be placed in any playlist.
+            </p>
+          )}
+          {planError && <p className="text-red-400 text-sm mt-4">{planError}</p>}
+          {(status.preview.status === 'draft' || status.preview.status === 'failed') && (
+            <Button
+              onClick={applyPlan}
+              disabled={applying || status.preview.playlists.length === 0}
+              className="mt-6 mr-6 disabled:opacity-50"
+            >
+              {applying ? 'Applying...' : 'Apply this plan'}
+            </Button>
+          )}
+          <button
+            onClick={() => router.push('/dashboard')}
+            className="mt-6 text-text-muted hover:text-text-cream underline"
+          >
+            Back to dashboard
+          </button>
+        </div>
+      )}
+
+      {/* Cancel / Cancelled summary */}
+      {status?.dry_run && status.status === 'completed' ? null : status?.status === 'cancelled' ? (
+        <div className="w-full max-w-md mb-8 text-center">
+          {status.result?.playlists?.length ? (
+            <>
+              <p className="text-text-muted mb-2">These playlists were already created:</p>
+              <ul className="text-text-cream">
+                {status.result.playlists.map((p) => (
+                  <li key={p.spotify_id}>
+                    <a href={p.spotify_url} target="_blank" rel="noopener noreferrer" className="hover:underline">
+                      {p.name}
+                    </a>{' '}
+                    <span className="text-text-muted">({p.song_count} songs)</span>
+                  </li>
+                ))}
+              </ul>
+            </>
+          ) : (
+            <p className="text-text-muted">No playlists were created.</p>
+          )}
+          <button
+            onClick={() => router.push('/dashboard')}
+            className="mt-6 text-text-muted hover:text-text-cream underline"
+          >
+            Back to dashboard
+          </button>
+        </div>
+      ) : (
+        status?.status !== 'failed' && (
+          <button
+            onClick={handleCancel}
+            disabled={cancelling}
+            className="mb-8 text-sm text-text-muted hover:text-text-cream underline disabled:opacity-50"
+          >
+            {cancelling ? 'Cancelling...' : 'Cancel'}
+          </button>
+        )
+      )}
+
       {/* Discovered Genres */}
       {status?.genres_discovered && status.genres_discovered.length > 0 && (
         <div className="w-full max-w-lg">
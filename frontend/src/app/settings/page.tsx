@@ -3,7 +3,7 @@
 import { useState, useEffect } from 'react';
 import { useRouter } from 'next/navigation';
 import { Button } from '@/components/Button';
-import { getSettings, updateSettings, UserSettings } from '@/lib/api';
+import { AutoSync, getSettings, updateSettings, UnlikedTracks, UserSettings } from '@/lib/api';
 
 export default function Settings() {
   const router = useRouter();
@@ -14,6 +14,8 @@ export default function Settings() {
   // Local state for inputs
   const [nameTemplate, setNameTemplate] = useState('');
   const [descTemplate, setDescTemplate] = useState('');
+  const [unlikedTracks, setUnlikedTracks] = useState<UnlikedTracks>('remove');
+  const [autoSync, setAutoSync] = useState<AutoSync>('off');
 
   useEffect(() => {
     async function load() {
@@ -22,6 +24,8 @@ export default function Settings() {
         setSettings(data);
         setNameTemplate(data.name_template);
         setDescTemplate(data.description_template);
+        setUnlikedTracks(data.unliked_tracks ?? 'remove');
+        setAutoSync(data.auto_sync ?? 'off');
       } catch (err) {
         console.error('Failed to load settings', err);
       } finally {
@@ -39,7 +43,7 @@ export default function Settings() {
 
     setSaving(true);
     try {
-      const updated = await updateSettings(nameTemplate, descTemplate);
+      const updated = await updateSettings(nameTemplate, descTemplate, unlikedTracks, autoSync);
       setSettings(updated);
       alert('Settings saved!');
     } catch (err) {
@@ -141,6 +145,45 @@ export default function Settings() {
             </div>
           </div>
 
+          {/* Unliked Songs */}
+          <div>
+            <label className="block text-text-cream font-medium mb-2">
+              Unliked Songs
+            </label>
+            <p className="text-sm text-text-muted mb-4">
+              What should syncing do with songs you no longer like?
+            </p>
+            <select
+              value={unlikedTracks}
+              onChange={(e) => setUnlikedTracks(e.target.value as UnlikedTracks)}
+              className="w-full bg-bg-dark border border-gray-700 rounded-lg px-4 py-3 text-text-cream focus:ring-2 focus:ring-accent-orange outline-none transition-all"
+            >
+              <option value="remove">Remove them from genre playlists</option>
+              <option value="archive">Move them to an &quot;Unliked&quot; playlist</option>
+              <option value="keep">Keep them where they are</option>
+            </select>
+          </div>
+
+          {/* Auto-sync */}
+          <div>
+            <label className="block text-text-cream font-medium mb-2">
+              Auto-sync
+            </label>
+            <p className="text-sm text-text-muted mb-4">
+              Keep your crates up to date in the background, even while you&apos;re away.
+            </p>
+            <select
+              value={autoSync}
+              onChange={(e) => setAutoSync(e.target.value as AutoSync)}
+              className="w-full bg-bg-dark border border-gray-700 rounded-lg px-4 py-3 text-text-cream focus:ring-2 focus:ring-accent-orange outline-none transition-all"
+            >
+              <option value="off">Off</option>
+              <option value="hourly">Every hour</option>
+              <option value="daily">Every day</option>
+              <option value="weekly">Every week</option>
+            </select>
+          </div>
+
           {/* Preview Card */}
           <div className="bg-bg-dark rounded-lg p-6 border border-gray-700">
             <h3 className="text-xs font-bold text-text-muted uppercase tracking-wider mb-4">
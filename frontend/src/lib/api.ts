@@ -12,7 +12,7 @@ export async function fetchUser() {
   return res.json();
 }
 
-export async function startOrganize(playlistCount: number, replaceExisting: boolean) {
+export async function startOrganize(playlistCount: number, replaceExisting: boolean, dryRun = false) {
   const res = await fetch(`${API_URL}/api/organize`, {
     method: 'POST',
     credentials: 'include',
@@ -22,11 +22,115 @@ export async function startOrganize(playlistCount: number, replaceExisting: bool
     body: JSON.stringify({
       playlist_count: playlistCount,
       replace_existing: replaceExisting,
+      dry_run: dryRun,
     }),
   });
 
   if (!res.ok) {
-    throw new Error('Failed to start organize');
+    // 409 means a playlist sync for this user is still running
+    const data = await res.json().catch(() => ({}));
+    throw new Error(data.error || 'Failed to start organize');
+  }
+
+  // Starting again while a job is queued or running returns that job
+  return res.json();
+}
+
+export interface OrganizePlan {
+  id: string;
+  status: 'draft' | 'applying' | 'applied' | 'failed';
+  playlists: Array<{
+    genre: string;
+    name: string;
+    description: string;
+    action: 'create' | 'replace' | 'append';
+    target_playlist_id?: string;
+    tracks: Array<{ id: string; name: string; artists: string[] }>;
+  }>;
+  merged_into_other: Array<{ genre: string; song_count: number }>;
+  unplaced_tracks: number;
+}
+
+// A single edit to an organize plan; playlists are identified by genre
+export type PlanEdit =
+  | { op: 'move_tracks'; track_ids: string[]; to_genre: string }
+  | { op: 'drop_genre'; genre: string }
+  | { op: 'rename'; genre: string; name: string; description?: string }
+  | { op: 'set_action'; genre: string; action: 'create' | 'replace' | 'append'; target_playlist_id?: string };
+
+export async function getOrganizePlan(planId: string): Promise<OrganizePlan> {
+  const res = await fetch(`${API_URL}/api/organize/plans/${planId}`, {
+    credentials: 'include',
+  });
+
+  if (!res.ok) {
+    throw new Error('Failed to get plan');
+  }
+
+  return res.json();
+}
+
+// Applies every edit or, if one is invalid, none of them
+export async function editOrganizePlan(planId: string, operations: PlanEdit[]): Promise<OrganizePlan> {
+  const res = await fetch(`${API_URL}/api/organize/plans/${planId}`, {
+    method: 'PATCH',
+    credentials: 'include',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ operations }),
+  });
+
+  if (!res.ok) {
+    const data = await res.json().catch(() => ({}));
+    throw new Error(data.error || 'Failed to edit plan');
+  }
+
+  return res.json();
+}
+
+// Starts a job writing the plan to Spotify; follow it like any organize job
+export async function applyOrganizePlan(planId: string): Promise<{ job_id: string; plan_id: string }> {
+  const res = await fetch(`${API_URL}/api/organize/plans/${planId}/apply`, {
+    method: 'POST',
+    credentials: 'include',
+  });
+
+  if (!res.ok) {
+    const data = await res.json().catch(() => ({}));
+    throw new Error(data.error || 'Failed to apply plan');
+  }
+
+  return res.json();
+}
+
+export interface OrganizeJobSummary {
+  id: string;
+  playlist_count: number;
+  replace_existing: boolean;
+  dry_run: boolean;
+  status: string;
+  created_at: string;
+  completed_at?: string;
+  error?: string;
+  result?: {
+    playlists: Array<{
+      name: string;
+      genre: string;
+      spotify_id: string;
+      spotify_url: string;
+      song_count: number;
+    }>;
+  };
+}
+
+export async function getOrganizeHistory(): Promise<{ jobs: OrganizeJobSummary[] }> {
+  const res = await fetch(`${API_URL}/api/organize`, {
+    credentials: 'include',
+  });
+
+  if (!res.ok) {
+    throw new Error('Failed to get organize history');
   }
 
   return res.json();
@@ -44,6 +148,27 @@ export async function getOrganizeStatus(jobId: string) {
   return res.json();
 }
 
+// Streams organize job progress as Server-Sent Events. The stream starts with
+// a "status" event and ends with "done"; see GET /api/organize/:id/events.
+export function subscribeOrganizeEvents(jobId: string): EventSource {
+  return new EventSource(`${API_URL}/api/organize/${jobId}/events`, {
+    withCredentials: true,
+  });
+}
+
+export async function cancelOrganize(jobId: string) {
+  const res = await fetch(`${API_URL}/api/organize/${jobId}`, {
+    method: 'DELETE',
+    credentials: 'include',
+  });
+
+  if (!res.ok) {
+    throw new Error('Failed to cancel organize');
+  }
+
+  return res.json();
+}
+
 export async function logout() {
   await fetch(`${API_URL}/api/auth/logout`, {
     method: 'POST',
@@ -66,8 +191,17 @@ export interface UserSettings {
   name_template: string;
   description_template: string;
   is_premium: boolean;
+  unliked_tracks: UnlikedTracks;
+  unliked_playlist_id?: string | null;
+  auto_sync: AutoSync;
 }
 
+// How often playlists are synced in the background
+export type AutoSync = 'off' | 'hourly' | 'daily' | 'weekly';
+
+// What syncing does with tracks that are no longer liked
+export type UnlikedTracks = 'keep' | 'remove' | 'archive';
+
 export async function getSettings(): Promise<UserSettings> {
   const response = await fetch(`${API_URL}/api/settings`, {
     credentials: 'include',
@@ -80,7 +214,9 @@ export async function getSettings(): Promise<UserSettings> {
 
 export async function updateSettings(
   nameTemplate: string,
-  descriptionTemplate: string
+  descriptionTemplate: string,
+  unlikedTracks?: UnlikedTracks,
+  autoSync?: AutoSync
 ): Promise<UserSettings> {
   const response = await fetch(`${API_URL}/api/settings`, {
     method: 'PUT',
@@ -89,6 +225,8 @@ export async function updateSettings(
     body: JSON.stringify({
       name_template: nameTemplate,
       description_template: descriptionTemplate,
+      unliked_tracks: unlikedTracks,
+      auto_sync: autoSync,
     }),
   });
   if (!response.ok) {
@@ -97,6 +235,30 @@ export async function updateSettings(
   return response.json();
 }
 
+export interface AutoSyncRun {
+  id: string;
+  schedule: AutoSync;
+  scheduled_for: string;
+  status: 'running' | 'succeeded' | 'failed' | 'skipped';
+  playlists_updated: number;
+  tracks_added: number;
+  tracks_removed: number;
+  error_message: string;
+  started_at: string;
+  finished_at: string | null;
+}
+
+export async function getAutoSyncRuns(): Promise<{
+  schedule: AutoSync;
+  runs: AutoSyncRun[];
+}> {
+  const response = await fetch(`${API_URL}/api/auto-sync/runs`, {
+    credentials: 'include',
+  });
+  if (!response.ok) throw new Error('Failed to fetch auto-sync runs');
+  return response.json();
+}
+
 export interface ManagedPlaylist {
   spotify_id: string;
   name: string;
@@ -107,8 +269,13 @@ export interface ManagedPlaylist {
   custom_name?: string;
   custom_description?: string;
   last_synced?: string;
+  sync_policy: SyncPolicy;
 }
 
+// How syncing treats a playlist: match its genre exactly, only add new
+// songs, or never touch it
+export type SyncPolicy = 'mirror' | 'append_only' | 'frozen';
+
 export async function getPlaylists(): Promise<{
   playlists: ManagedPlaylist[];
   total_songs: number;
@@ -120,7 +287,37 @@ export async function getPlaylists(): Promise<{
   return response.json();
 }
 
-export async function refreshPlaylist(id: string): Promise<{ song_count: number }> {
+// Tracks lists what sync or organize put in the playlist; user_tracks the
+// songs added by hand, which syncing keeps
+export interface PlaylistDetail {
+  spotify_id: string;
+  name: string;
+  genre: string;
+  spotify_url: string;
+  sync_policy: SyncPolicy;
+  last_synced: string | null;
+  tracks: string[];
+  user_tracks: string[];
+}
+
+export async function getPlaylist(id: string): Promise<PlaylistDetail> {
+  const response = await fetch(`${API_URL}/api/playlists/${id}`, {
+    credentials: 'include',
+  });
+  if (!response.ok) throw new Error('Failed to fetch playlist');
+  return response.json();
+}
+
+export async function refreshPlaylist(
+  id: string
+): Promise<{
+  song_count: number;
+  added: string[];
+  removed: string[];
+  unliked: string[];
+  user_owned: string[];
+  archived: number;
+}> {
   const response = await fetch(`${API_URL}/api/playlists/${id}/refresh`, {
     method: 'POST',
     credentials: 'include',
@@ -132,7 +329,8 @@ export async function refreshPlaylist(id: string): Promise<{ song_count: number
 export async function updatePlaylist(
   id: string,
   customName?: string,
-  customDescription?: string
+  customDescription?: string,
+  syncPolicy?: SyncPolicy
 ): Promise<void> {
   const response = await fetch(`${API_URL}/api/playlists/${id}`, {
     method: 'PATCH',
@@ -141,6 +339,7 @@ export async function updatePlaylist(
     body: JSON.stringify({
       custom_name: customName,
       custom_description: customDescription,
+      sync_policy: syncPolicy,
     }),
   });
   if (!response.ok) throw new Error('Failed to update playlist');
@@ -166,10 +365,24 @@ export interface SyncStatus {
   playlists: PlaylistSyncStatus[];
 }
 
+export interface PlaylistChange {
+  playlist_id: string;
+  added: string[];
+  removed: string[];
+  unliked: string[];
+  unchanged: number;
+}
+
 export interface SyncAllResult {
   playlists_updated: number;
   total_songs: number;
+  tracks_added: number;
+  tracks_removed: number;
+  tracks_unliked: number;
+  tracks_archived: number;
+  playlists_frozen: number;
   failed_playlists?: string[];
+  changes: PlaylistChange[];
 }
 
 // Custom error class for API errors with status codes
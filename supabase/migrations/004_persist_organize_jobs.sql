@@ -0,0 +1,30 @@
+-- Persist organize job progress so status survives restarts and works
+-- across replicas
+
+-- Jobs are keyed by Spotify user ID, like user_settings and playlist_overrides.
+-- They reference users once the backend stores them, see 013.
+DROP POLICY IF EXISTS "Users can view own jobs" ON organize_jobs;
+DROP POLICY IF EXISTS "Users can insert own jobs" ON organize_jobs;
+DROP POLICY IF EXISTS "Users can update own jobs" ON organize_jobs;
+
+ALTER TABLE organize_jobs DROP CONSTRAINT IF EXISTS organize_jobs_user_id_fkey;
+ALTER TABLE organize_jobs ALTER COLUMN user_id TYPE TEXT USING user_id::text;
+ALTER TABLE organize_jobs ALTER COLUMN user_id SET NOT NULL;
+
+-- Progress fields reported by GET /api/organize/:id
+ALTER TABLE organize_jobs ADD COLUMN IF NOT EXISTS stage VARCHAR(50) DEFAULT 'initializing';
+ALTER TABLE organize_jobs ADD COLUMN IF NOT EXISTS genres_discovered JSONB DEFAULT '[]'::jsonb;
+ALTER TABLE organize_jobs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW();
+ALTER TABLE organize_jobs ALTER COLUMN total_songs SET DEFAULT 0;
+
+-- Job history is listed newest first per user
+CREATE INDEX IF NOT EXISTS idx_organize_jobs_user_created ON organize_jobs(user_id, created_at DESC);
+
+CREATE POLICY "Users can view own jobs" ON organize_jobs
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
+
+CREATE POLICY "Users can insert own jobs" ON organize_jobs
+  FOR INSERT WITH CHECK (user_id = current_setting('app.user_id', true));
+
+CREATE POLICY "Users can update own jobs" ON organize_jobs
+  FOR UPDATE USING (user_id = current_setting('app.user_id', true));
@@ -0,0 +1,34 @@
+-- Dry runs produce a plan that can be edited and then applied
+CREATE TABLE IF NOT EXISTS organize_plans (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'draft',
+  playlist_count INT NOT NULL,
+  replace_existing BOOLEAN NOT NULL DEFAULT FALSE,
+  apply_job_id UUID,
+  -- Bumped by every update, which only applies to the version it read
+  version INT NOT NULL DEFAULT 0,
+  playlists JSONB NOT NULL DEFAULT '[]'::jsonb,
+  merged_into_other JSONB NOT NULL DEFAULT '[]'::jsonb,
+  unplaced_tracks INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_organize_plans_created ON organize_plans(created_at);
+
+ALTER TABLE organize_plans ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own plans" ON organize_plans
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
+
+CREATE POLICY "Users can insert own plans" ON organize_plans
+  FOR INSERT WITH CHECK (user_id = current_setting('app.user_id', true));
+
+CREATE POLICY "Users can update own plans" ON organize_plans
+  FOR UPDATE USING (user_id = current_setting('app.user_id', true));
+
+-- Dry-run jobs produce a plan, which the job points at
+ALTER TABLE organize_jobs ADD COLUMN IF NOT EXISTS dry_run BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE organize_jobs
+  ADD COLUMN IF NOT EXISTS plan_id UUID REFERENCES organize_plans(id) ON DELETE SET NULL;
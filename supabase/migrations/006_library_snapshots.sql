@@ -0,0 +1,29 @@
+-- A stored copy of each user's liked songs, so syncing only has to fetch
+-- what was liked since the newest stored track
+CREATE TABLE IF NOT EXISTS library_snapshots (
+  user_id TEXT PRIMARY KEY,
+  track_count INT NOT NULL DEFAULT 0,
+  cursor TIMESTAMPTZ,
+  full_sync_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS library_tracks (
+  user_id TEXT NOT NULL,
+  track_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  artists JSONB NOT NULL DEFAULT '[]'::jsonb,
+  added_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, track_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_library_tracks_added ON library_tracks(user_id, added_at DESC);
+
+ALTER TABLE library_snapshots ENABLE ROW LEVEL SECURITY;
+ALTER TABLE library_tracks ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own library snapshot" ON library_snapshots
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
+
+CREATE POLICY "Users can view own library tracks" ON library_tracks
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
@@ -0,0 +1,20 @@
+-- Keep the genres behind each stored track and the parent genre the
+-- classifier chose for it, so endpoints can reuse them instead of looking
+-- up every artist again
+ALTER TABLE library_tracks ADD COLUMN IF NOT EXISTS parent_genre TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_library_tracks_parent_genre ON library_tracks(user_id, parent_genre);
+
+CREATE TABLE IF NOT EXISTS library_artists (
+  user_id TEXT NOT NULL,
+  artist_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  genres JSONB NOT NULL DEFAULT '[]'::jsonb,
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  PRIMARY KEY (user_id, artist_id)
+);
+
+ALTER TABLE library_artists ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own library artists" ON library_artists
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
@@ -0,0 +1,12 @@
+-- Artist genres are shared by every user and change rarely, so they are
+-- cached across requests instead of being looked up for each library
+CREATE TABLE IF NOT EXISTS artist_genres (
+  artist_id TEXT PRIMARY KEY,
+  genres JSONB NOT NULL DEFAULT '[]'::jsonb,
+  fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_artist_genres_fetched ON artist_genres(fetched_at);
+
+-- Only the backend's service role reads and writes the cache
+ALTER TABLE artist_genres ENABLE ROW LEVEL SECURITY;
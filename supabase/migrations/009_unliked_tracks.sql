@@ -0,0 +1,5 @@
+-- What syncing does with playlist tracks that are no longer liked: keep them,
+-- remove them, or move them to an archive playlist
+ALTER TABLE user_settings
+  ADD COLUMN IF NOT EXISTS unliked_tracks VARCHAR(20) NOT NULL DEFAULT 'remove',
+  ADD COLUMN IF NOT EXISTS unliked_playlist_id TEXT;
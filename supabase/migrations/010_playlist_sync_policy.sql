@@ -0,0 +1,5 @@
+-- How syncing treats each genre playlist: mirror the genre exactly, only add
+-- new songs, or leave the playlist alone
+ALTER TABLE playlist_overrides
+  ADD COLUMN IF NOT EXISTS sync_policy VARCHAR(20) NOT NULL DEFAULT 'mirror'
+    CHECK (sync_policy IN ('mirror', 'append_only', 'frozen'));
@@ -0,0 +1,4 @@
+-- Tracks sync or organize put in each playlist; any other track was added by
+-- the user and is kept. NULL means nothing was recorded yet.
+ALTER TABLE playlist_overrides
+  ADD COLUMN IF NOT EXISTS managed_tracks JSONB;
@@ -0,0 +1,31 @@
+-- Users can opt in to having their playlists synced in the background
+ALTER TABLE user_settings
+  ADD COLUMN IF NOT EXISTS auto_sync VARCHAR(20) NOT NULL DEFAULT 'off'
+    CHECK (auto_sync IN ('off', 'hourly', 'daily', 'weekly'));
+
+CREATE INDEX IF NOT EXISTS idx_user_settings_auto_sync ON user_settings(auto_sync)
+  WHERE auto_sync <> 'off';
+
+-- One row per background sync. The unique slot lets only one replica claim
+-- a user's run for each period.
+CREATE TABLE IF NOT EXISTS auto_sync_runs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id TEXT NOT NULL,
+  schedule VARCHAR(20) NOT NULL,
+  scheduled_for TIMESTAMPTZ NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'running',
+  playlists_updated INT NOT NULL DEFAULT 0,
+  tracks_added INT NOT NULL DEFAULT 0,
+  tracks_removed INT NOT NULL DEFAULT 0,
+  error_message TEXT,
+  started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  finished_at TIMESTAMPTZ,
+  UNIQUE (user_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_auto_sync_runs_user ON auto_sync_runs(user_id, started_at DESC);
+
+ALTER TABLE auto_sync_runs ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view own auto-sync runs" ON auto_sync_runs
+  FOR SELECT USING (user_id = current_setting('app.user_id', true));
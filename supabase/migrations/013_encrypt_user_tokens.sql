@@ -0,0 +1,53 @@
+-- Tokens are now encrypted by the backend before they are stored. Drop any
+-- stored in plain text; those users are asked to sign in again.
+UPDATE users
+SET access_token = NULL,
+    refresh_token = NULL,
+    token_expires_at = NULL
+WHERE access_token NOT LIKE 'v1:%'
+   OR refresh_token NOT LIKE 'v1:%';
+
+COMMENT ON COLUMN users.access_token IS 'AES-256-GCM encrypted, see internal/auth';
+COMMENT ON COLUMN users.refresh_token IS 'AES-256-GCM encrypted, see internal/auth';
+
+-- Users are now stored when they sign in, so the tables keyed by their
+-- Spotify ID can reference them. Users who used the app before then get a
+-- row to sign in to.
+INSERT INTO users (spotify_id)
+SELECT user_id FROM organize_jobs
+UNION SELECT user_id FROM organize_plans
+UNION SELECT user_id FROM library_snapshots
+UNION SELECT user_id FROM library_tracks
+UNION SELECT user_id FROM library_artists
+UNION SELECT user_id FROM auto_sync_runs
+ON CONFLICT (spotify_id) DO NOTHING;
+
+ALTER TABLE organize_jobs DROP CONSTRAINT IF EXISTS organize_jobs_user_id_fkey;
+ALTER TABLE organize_jobs
+  ADD CONSTRAINT organize_jobs_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;
+
+ALTER TABLE organize_plans DROP CONSTRAINT IF EXISTS organize_plans_user_id_fkey;
+ALTER TABLE organize_plans
+  ADD CONSTRAINT organize_plans_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;
+
+ALTER TABLE library_snapshots DROP CONSTRAINT IF EXISTS library_snapshots_user_id_fkey;
+ALTER TABLE library_snapshots
+  ADD CONSTRAINT library_snapshots_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;
+
+ALTER TABLE library_tracks DROP CONSTRAINT IF EXISTS library_tracks_user_id_fkey;
+ALTER TABLE library_tracks
+  ADD CONSTRAINT library_tracks_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;
+
+ALTER TABLE library_artists DROP CONSTRAINT IF EXISTS library_artists_user_id_fkey;
+ALTER TABLE library_artists
+  ADD CONSTRAINT library_artists_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;
+
+ALTER TABLE auto_sync_runs DROP CONSTRAINT IF EXISTS auto_sync_runs_user_id_fkey;
+ALTER TABLE auto_sync_runs
+  ADD CONSTRAINT auto_sync_runs_user_id_fkey
+  FOREIGN KEY (user_id) REFERENCES users(spotify_id) ON DELETE CASCADE;